@@ -0,0 +1,227 @@
+package krakenapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Maximum number of trades returned by a Trades call. A shorter page means the backfill caught up.
+var TradesPageSize = 1000
+
+// Progress of the backfill of a pair, as saved in checkpoints.
+type BackfillCursor struct {
+	Since    string   `json:"since"`     // since cursor of the next page
+	LastTime float64  `json:"last_time"` // time of the latest trade delivered
+	LastKeys []string `json:"last_keys"` // trades delivered at LastTime, skipped when pages overlap
+	Trades   int      `json:"trades"`    // number of trades delivered
+}
+
+func tradeKey(trade RecentTrade) string {
+	if trade.TradeId != 0 {
+		return strconv.FormatInt(trade.TradeId, 10)
+	}
+
+	return fmt.Sprintf("%v/%v/%v/%s/%s/%s", trade.Time, trade.Price, trade.Volume, trade.Type, trade.TradeType, trade.Misc)
+}
+
+// Returns the trades of a page not delivered yet.
+func (c *BackfillCursor) filter(trades []RecentTrade) []RecentTrade {
+	delivered := make(map[string]bool)
+	for _, key := range c.LastKeys {
+		delivered[key] = true
+	}
+
+	fresh := []RecentTrade{}
+
+	for _, trade := range trades {
+		if trade.Time < c.LastTime || (trade.Time == c.LastTime && delivered[tradeKey(trade)]) {
+			continue
+		}
+
+		fresh = append(fresh, trade)
+	}
+
+	return fresh
+}
+
+// Move the cursor after delivered trades, last being the page's last id.
+func (c *BackfillCursor) advance(delivered []RecentTrade, last string) {
+	for _, trade := range delivered {
+		if trade.Time > c.LastTime {
+			c.LastTime = trade.Time
+			c.LastKeys = nil
+		}
+
+		if trade.Time == c.LastTime {
+			c.LastKeys = append(c.LastKeys, tradeKey(trade))
+		}
+	}
+
+	if last != "" {
+		c.Since = last
+	}
+
+	c.Trades += len(delivered)
+}
+
+/*
+Backfill fetches the complete trade history of pairs, from a start time to now,
+by walking Trades pages with their since cursors.
+
+Trades are delivered in order to OnTrades, each trade once, even when pages
+overlap. Progress is saved to a checkpoint file after each page, so that a new
+Backfill using the same file resumes where the previous one stopped. Running it
+again once caught up fetches the trades made since.
+
+Progress is saved once OnTrades returned: if the process stops in between, the
+page is delivered again on resume. Delivery is at-least-once across restarts, so
+OnTrades should tolerate trades it already stored (ie: keyed by TradeId).
+
+Calls wait for the client's RateLimiter, or for Limiter if the client has none.
+Calls refused by Kraken's rate limits are retried after RetryDelay.
+*/
+type Backfill struct {
+	api        *KrakenApi
+	Pairs      []string
+	Start      time.Time
+	Path       string                                        // checkpoint file, "" to disable checkpoints
+	Limiter    *RateLimiter                                  // used if the client has no RateLimiter
+	RetryDelay time.Duration                                 // delay before retrying a rate limited call
+	OnTrades   func(pair string, trades []RecentTrade) error // returning an error stops the backfill
+
+	cursors map[string]*BackfillCursor
+}
+
+// Create a backfill, loading its progress from the checkpoint file at path if it exists.
+func (api *KrakenApi) NewBackfill(pairs []string, start time.Time, path string, on_trades func(pair string, trades []RecentTrade) error) (*Backfill, error) {
+	b := &Backfill{
+		api:        api,
+		Pairs:      pairs,
+		Start:      start,
+		Path:       path,
+		Limiter:    NewRateLimiter(time.Second, 1),
+		RetryDelay: 5 * time.Second,
+		OnTrades:   on_trades,
+		cursors:    make(map[string]*BackfillCursor),
+	}
+
+	if path == "" {
+		return b, nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(content, &b.cursors)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Returns the progress of the backfill of pair.
+func (b *Backfill) Cursor(pair string) BackfillCursor {
+	if cursor, ok := b.cursors[pair]; ok {
+		return *cursor
+	}
+
+	return BackfillCursor{Since: strconv.FormatInt(b.Start.UnixNano(), 10)}
+}
+
+func (b *Backfill) save() error {
+	if b.Path == "" {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(b.cursors, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(b.Path, content)
+}
+
+// Backfill all pairs, one after the other, until they caught up or the context is done.
+func (b *Backfill) Run(ctx context.Context) error {
+	for _, pair := range b.Pairs {
+		err := b.runPair(ctx, pair)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *Backfill) runPair(ctx context.Context, pair string) error {
+	cursor := b.Cursor(pair)
+	b.cursors[pair] = &cursor
+
+	for {
+		if b.api.RateLimiter == nil && b.Limiter != nil {
+			err := b.Limiter.Wait(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		pages, last, err := b.api.trades(pair, cursor.Since)
+		if api_err, ok := err.(*ApiError); ok && api_err.RateLimited() {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(b.RetryDelay):
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		count := 0
+		fresh := []RecentTrade{}
+
+		for _, trades := range pages {
+			count += len(trades)
+			fresh = append(fresh, cursor.filter(trades)...)
+		}
+
+		if len(fresh) > 0 && b.OnTrades != nil {
+			err = b.OnTrades(pair, fresh)
+			if err != nil {
+				return err
+			}
+		}
+
+		// A full page without new trades (ie: more than a page of trades at the same
+		// time) still moves last: only stop once it does not move anymore.
+		moved := last != "" && last != cursor.Since
+
+		cursor.advance(fresh, last)
+
+		err = b.save()
+		if err != nil {
+			return err
+		}
+
+		if count < TradesPageSize || !moved {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package krakenapi
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestApiTradesLast(t *testing.T) {
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error":[],"result":{"last":"1700000000123456789","XXBTZEUR":[["100.0","0.5",1700000000.1234,"b","l","",42]]}}`))
+	})
+	defer server.Close()
+
+	// The pair's trades must not depend on the order of the result keys.
+	for i := 0; i < 10; i++ {
+		trades, last, err := client.trades("XXBTZEUR", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if last != "1700000000123456789" {
+			t.Errorf("Unexpected last %s", last)
+		}
+
+		if len(trades["XXBTZEUR"]) != 1 || trades["XXBTZEUR"][0].TradeId != 42 || trades["XXBTZEUR"][0].Type != "b" {
+			t.Fatalf("Unexpected trades %v", trades)
+		}
+	}
+}
+
+func TestBackfill(t *testing.T) {
+	page_size := TradesPageSize
+	TradesPageSize = 2
+	defer func() { TradesPageSize = page_size }()
+
+	dir, err := ioutil.TempDir("", "backfill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	start := time.Unix(1000, 0)
+
+	pages := map[string]string{
+		"1000000000000": `{"error":[],"result":{"XXBTZEUR":[["1","1",1001,"b","l","",1],["1","1",1002,"b","l","",2]],"last":"2"}}`,
+		"2":             `{"error":[],"result":{"XXBTZEUR":[["1","1",1002,"b","l","",2],["1","1",1002,"s","l","",3]],"last":"3"}}`,
+		"3":             `{"error":[],"result":{"XXBTZEUR":[["1","1",1003,"b","l","",4]],"last":"4"}}`,
+		"4":             `{"error":[],"result":{"XXBTZEUR":[],"last":"4"}}`,
+	}
+	rate_limited := true
+
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		if rate_limited {
+			rate_limited = false
+			w.Write([]byte(`{"error":["EGeneral:Too many requests"]}`))
+			return
+		}
+
+		page, ok := pages[r.FormValue("since")]
+		if !ok {
+			t.Errorf("Unexpected since %s", r.FormValue("since"))
+		}
+
+		w.Write([]byte(page))
+	})
+	defer server.Close()
+
+	ids := []int64{}
+	on_trades := func(pair string, trades []RecentTrade) error {
+		for _, trade := range trades {
+			ids = append(ids, trade.TradeId)
+		}
+		return nil
+	}
+
+	path := filepath.Join(dir, "checkpoint.json")
+
+	backfill, err := client.NewBackfill([]string{"XXBTZEUR"}, start, path, on_trades)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backfill.Limiter = nil
+	backfill.RetryDelay = time.Millisecond
+
+	err = backfill.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != 4 || ids[0] != 1 || ids[1] != 2 || ids[2] != 3 || ids[3] != 4 {
+		t.Errorf("Unexpected trades %v", ids)
+	}
+
+	// Resume from the checkpoint: nothing new
+	backfill, err = client.NewBackfill([]string{"XXBTZEUR"}, start, path, on_trades)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backfill.Limiter = nil
+
+	cursor := backfill.Cursor("XXBTZEUR")
+	if cursor.Since != "4" || cursor.Trades != 4 || cursor.LastTime != 1003 {
+		t.Errorf("Unexpected checkpoint %+v", cursor)
+	}
+
+	err = backfill.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != 4 {
+		t.Errorf("Resumed backfill delivered trades again: %v", ids)
+	}
+}
+
+func TestBackfillSameTime(t *testing.T) {
+	page_size := TradesPageSize
+	TradesPageSize = 2
+	defer func() { TradesPageSize = page_size }()
+
+	// More than a page of trades at the same time: the second page has no new trade
+	pages := map[string]string{
+		"1000000000000": `{"error":[],"result":{"XXBTZEUR":[["1","1",1001,"b","l","",1],["1","1",1001,"b","l","",2]],"last":"2"}}`,
+		"2":             `{"error":[],"result":{"XXBTZEUR":[["1","1",1001,"b","l","",1],["1","1",1001,"b","l","",2]],"last":"3"}}`,
+		"3":             `{"error":[],"result":{"XXBTZEUR":[["1","1",1001,"b","l","",3],["1","1",1002,"b","l","",4]],"last":"4"}}`,
+		"4":             `{"error":[],"result":{"XXBTZEUR":[["1","1",1002,"b","l","",4],["1","1",1002,"b","l","",4]],"last":"4"}}`,
+	}
+
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.FormValue("since")]
+		if !ok {
+			t.Errorf("Unexpected since %s", r.FormValue("since"))
+		}
+
+		w.Write([]byte(page))
+	})
+	defer server.Close()
+
+	ids := []int64{}
+	on_trades := func(pair string, trades []RecentTrade) error {
+		for _, trade := range trades {
+			ids = append(ids, trade.TradeId)
+		}
+		return nil
+	}
+
+	backfill, err := client.NewBackfill([]string{"XXBTZEUR"}, time.Unix(1000, 0), "", on_trades)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backfill.Limiter = nil
+
+	// The last page is full, but last does not move anymore
+	err = backfill.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != 4 || ids[2] != 3 || ids[3] != 4 {
+		t.Errorf("Unexpected trades %v", ids)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(20*time.Millisecond, 2)
+
+	started := time.Now()
+	for i := 0; i < 4; i++ {
+		err := limiter.Wait(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 2 calls at once, then 2 calls 20ms apart
+	elapsed := time.Since(started)
+	if elapsed < 35*time.Millisecond || elapsed > 200*time.Millisecond {
+		t.Errorf("Unexpected elapsed time %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	limiter.Wait(ctx)
+	err := limiter.Wait(ctx)
+	if err != context.Canceled {
+		t.Errorf("Expected canceled context, got %v", err)
+	}
+}
@@ -0,0 +1,264 @@
+package krakenapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Balance of an asset, split between funds held by open orders and free funds.
+type AvailableBalance struct {
+	Total float64 // total balance
+	Held  float64 // held by open orders, including their fees
+	Free  float64 // available for new orders
+}
+
+// Returned by BalanceCalculator.Check when an order exceeds the free balance.
+type InsufficientBalanceError struct {
+	Asset    string  // asset spent by the order
+	Required float64 // volume of the order
+	Max      float64 // maximum volume allowed by the free balance
+}
+
+func (e *InsufficientBalanceError) Error() string {
+	return fmt.Sprintf("Insufficient %s balance: order volume %f exceeds maximum %f", e.Asset, e.Required, e.Max)
+}
+
+/*
+BalanceCalculator computes the balances left free by open orders.
+
+Holds are computed from open orders: the remaining volume of sell orders, and the
+remaining volume times the limit price of buy orders, plus fees at the pair's
+highest taker fee. Margin orders hold no balance. When UseBalanceEx is set, the
+holds reported by Kraken (BalanceEx hold_trade) are used instead.
+*/
+type BalanceCalculator struct {
+	api          *KrakenApi
+	registry     *Registry
+	UseBalanceEx bool
+}
+
+// Create a balance calculator. The registry resolves pairs and provides fee schedules.
+func (api *KrakenApi) NewBalanceCalculator(registry *Registry) *BalanceCalculator {
+	return &BalanceCalculator{
+		api:      api,
+		registry: registry,
+	}
+}
+
+// Returns the total, held and free balance of each asset.
+func (c *BalanceCalculator) Available() (map[string]AvailableBalance, error) {
+	if c.UseBalanceEx {
+		return c.fromBalanceEx()
+	}
+
+	balances, err := c.api.ApiBalance()
+	if err != nil {
+		return nil, err
+	}
+
+	open, err := c.api.ApiOpenOrders(false, "")
+	if err != nil {
+		return nil, err
+	}
+
+	held := make(map[string]float64)
+
+	for _, order := range open.Open {
+		err = c.addHolds(held, order)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	available := make(map[string]AvailableBalance)
+
+	for asset, total := range balances {
+		available[asset] = AvailableBalance{Total: total, Held: held[asset], Free: total - held[asset]}
+	}
+
+	for asset, amount := range held {
+		if _, ok := available[asset]; !ok {
+			available[asset] = AvailableBalance{Held: amount, Free: -amount}
+		}
+	}
+
+	return available, nil
+}
+
+func (c *BalanceCalculator) fromBalanceEx() (map[string]AvailableBalance, error) {
+	balances, err := c.api.ApiBalanceEx()
+	if err != nil {
+		return nil, err
+	}
+
+	available := make(map[string]AvailableBalance)
+
+	for asset, balance := range balances {
+		available[asset] = AvailableBalance{
+			Total: balance.Balance,
+			Held:  balance.HoldTrade,
+			Free:  balance.Balance - balance.HoldTrade,
+		}
+	}
+
+	return available, nil
+}
+
+// Limit price of an open order: price2 for *-limit orders, price otherwise.
+func openOrderPrice(order Order) float64 {
+	if strings.HasSuffix(order.Descr.Ordertype, "-limit") {
+		return order.Descr.Price2
+	}
+
+	return order.Descr.Price
+}
+
+func hasFlag(oflags, flag string) bool {
+	for _, f := range strings.Split(oflags, ",") {
+		if f == flag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Add the amounts held by an open order to held, by asset.
+func (c *BalanceCalculator) addHolds(held map[string]float64, order Order) error {
+	if order.Descr.Leverage != "" && order.Descr.Leverage != "none" {
+		return nil
+	}
+
+	pair, err := c.registry.Pair(order.Descr.Pair)
+	if err != nil {
+		return err
+	}
+
+	price := openOrderPrice(order)
+	remaining := order.Vol - order.VolExec
+
+	if hasFlag(order.Oflags, "viqc") {
+		if price == 0 {
+			return nil
+		}
+		remaining /= price
+	}
+
+	request := &OrderRequest{Type: order.Descr.Type, Price: price, Volume: remaining, Oflags: order.Oflags}
+	fee := EstimateFee(pair, 0, request, false)
+
+	if order.Descr.Type == "buy" {
+		held[pair.Quote] += remaining * price
+	} else {
+		held[pair.Base] += remaining
+	}
+
+	held[fee.FeeCurrency] += fee.Fee
+
+	return nil
+}
+
+/*
+Returns the maximum volume of an order given the free balance of the spent asset,
+keeping room for fees at the pair's highest taker fee. The volume is in quote
+currency if viqc is set in the order flags.
+Orders without a price are valued at the ticker's mid price.
+*/
+func (c *BalanceCalculator) MaxVolume(order *OrderRequest) (float64, error) {
+	pair, err := c.registry.Pair(order.Pair)
+	if err != nil {
+		return 0, err
+	}
+
+	price, _ := order.limitAndTriggerPrices()
+	if price == 0 {
+		price = order.Price
+	}
+
+	if price == 0 {
+		pair_name, err := c.registry.ResolvePair(order.Pair)
+		if err != nil {
+			return 0, err
+		}
+
+		tickers, err := c.api.ApiTicker([]string{pair_name})
+		if err != nil {
+			return 0, err
+		}
+
+		for _, ticker := range tickers {
+			price = (ticker.Ask.Price + ticker.Bid.Price) / 2
+		}
+
+		if price == 0 {
+			return 0, fmt.Errorf("No price for %s", pair_name)
+		}
+	}
+
+	available, err := c.Available()
+	if err != nil {
+		return 0, err
+	}
+
+	priced := *order
+	priced.Price = price
+	priced.Volume = 1
+	fee := EstimateFee(pair, 0, &priced, false)
+
+	var max float64
+
+	if order.Type == "buy" {
+		cost := price
+		if fee.FeeCurrency == pair.Quote {
+			cost += fee.Fee
+		}
+
+		max = available[pair.Quote].Free / cost
+	} else {
+		spent := 1.0
+		if fee.FeeCurrency == pair.Base {
+			spent += fee.Fee
+		}
+
+		max = available[pair.Base].Free / spent
+	}
+
+	// viqc volumes are in quote currency
+	if hasFlag(order.Oflags, "viqc") {
+		max *= price
+	}
+
+	return max, nil
+}
+
+/*
+Check that the free balance covers an order. Returns an *InsufficientBalanceError
+if it does not. Margin orders are not checked.
+Can be used as KrakenApi.PreTradeCheck, or called from one.
+*/
+func (c *BalanceCalculator) Check(order *OrderRequest) error {
+	if order.Leverage != "" && order.Leverage != "none" {
+		return nil
+	}
+
+	max, err := c.MaxVolume(order)
+	if err != nil {
+		return err
+	}
+
+	if order.Volume > max+VOLUME_EPSILON {
+		pair, err := c.registry.Pair(order.Pair)
+		if err != nil {
+			return err
+		}
+
+		asset := pair.Base
+		if order.Type == "buy" {
+			asset = pair.Quote
+		}
+
+		return &InsufficientBalanceError{asset, order.Volume, max}
+	}
+
+	return nil
+}
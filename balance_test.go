@@ -0,0 +1,109 @@
+package krakenapi
+
+import (
+	"math"
+	"net/http"
+	"testing"
+)
+
+func createTestBalanceCalculator(t *testing.T) (*BalanceCalculator, func()) {
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case URL_PRIVATE_BALANCE:
+			w.Write([]byte(`{"error":[],"result":{"XXBT":"2.0000000000","ZEUR":"1000.0000"}}`))
+		case URL_PRIVATE_BALANCE_EX:
+			w.Write([]byte(`{"error":[],"result":{"XXBT":{"balance":"2.0000000000","hold_trade":"0.5000000000"}}}`))
+		case URL_PRIVATE_OPEN_ORDERS:
+			w.Write([]byte(`{"error":[],"result":{"open":{
+				"OBUY":{"status":"open","vol":"2","vol_exec":"1","oflags":"","descr":{"pair":"XBTEUR","type":"buy","ordertype":"limit","price":"100","price2":"0","leverage":"none"}},
+				"OSELL":{"status":"open","vol":"0.5","vol_exec":"0","oflags":"fciq","descr":{"pair":"XBTEUR","type":"sell","ordertype":"stop-loss-limit","price":"90","price2":"80","leverage":"none"}},
+				"OMARGIN":{"status":"open","vol":"10","vol_exec":"0","oflags":"","descr":{"pair":"XBTEUR","type":"buy","ordertype":"limit","price":"100","price2":"0","leverage":"2:1"}}
+			}}}`))
+		default:
+			t.Errorf("Unexpected query %s", r.URL.Path)
+		}
+	})
+
+	registry := createTestRegistry()
+	pairs := registry.pairs
+	pair := pairs["XXBTZEUR"]
+	pair.Fees = testFeeSchedule
+	pairs["XXBTZEUR"] = pair
+	registry.load(registry.assets, pairs)
+
+	return client.NewBalanceCalculator(registry), server.Close
+}
+
+func TestAvailableBalance(t *testing.T) {
+	calculator, close := createTestBalanceCalculator(t)
+	defer close()
+
+	available, err := calculator.Available()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// buy: 1 * 100 + 0.26% fee, sell: 0.5 * 80 * 0.26% fee in quote
+	eur_held := 100 + 0.26 + 0.104
+	if math.Abs(available["ZEUR"].Held-eur_held) > 1e-9 || math.Abs(available["ZEUR"].Free-(1000-eur_held)) > 1e-9 {
+		t.Errorf("Unexpected ZEUR balance %+v", available["ZEUR"])
+	}
+
+	if available["XXBT"].Total != 2 || available["XXBT"].Held != 0.5 || available["XXBT"].Free != 1.5 {
+		t.Errorf("Unexpected XXBT balance %+v", available["XXBT"])
+	}
+
+	calculator.UseBalanceEx = true
+
+	available, err = calculator.Available()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if available["XXBT"].Held != 0.5 || available["XXBT"].Free != 1.5 {
+		t.Errorf("Unexpected BalanceEx XXBT balance %+v", available["XXBT"])
+	}
+}
+
+func TestBalanceCheck(t *testing.T) {
+	calculator, close := createTestBalanceCalculator(t)
+	defer close()
+
+	order := &OrderRequest{Pair: "BTC/EUR", Type: "sell", OrderType: "limit", Price: 100, Volume: 1.5}
+
+	// fee charged in base currency
+	max, err := calculator.MaxVolume(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if math.Abs(max-1.5/1.0026) > 1e-9 {
+		t.Errorf("Unexpected sell maximum %f", max)
+	}
+
+	err = calculator.Check(order)
+	if _, ok := err.(*InsufficientBalanceError); !ok {
+		t.Errorf("Sell order should exceed the free XXBT balance, got %v", err)
+	}
+
+	// fee charged in quote currency
+	order.Oflags = "fciq"
+
+	err = calculator.Check(order)
+	if err != nil {
+		t.Error(err)
+	}
+
+	order = &OrderRequest{Pair: "BTC/EUR", Type: "buy", OrderType: "limit", Price: 100, Volume: 9}
+
+	err = calculator.Check(order)
+	if e, ok := err.(*InsufficientBalanceError); !ok || e.Asset != "ZEUR" || math.Abs(e.Max-(1000-100.364)/100.26) > 1e-9 {
+		t.Errorf("Buy order should exceed the free ZEUR balance, got %v", err)
+	}
+
+	order.Leverage = "2"
+	err = calculator.Check(order)
+	if err != nil {
+		t.Errorf("Margin orders should not be checked, got %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package krakenapi
+
+import (
+	"fmt"
+	"math"
+)
+
+// Outcome of sweeping an order book with a market order.
+type SweepResult struct {
+	Volume          float64 // base volume filled
+	Cost            float64 // quote amount spent or received
+	VWAP            float64 // average fill price
+	WorstPrice      float64 // price of the last level reached
+	Levels          int     // number of levels reached
+	Complete        bool    // whether the book was deep enough for the whole order
+	Slippage        float64 // relative distance of VWAP to the best price (ie: 0.001 for 0.1%), always positive
+	SlippageFromMid float64 // relative distance of VWAP to the mid price, always positive
+}
+
+// Returns the levels of the side a buy or sell order takes, best first.
+func (book PublicOrderBook) takenSide(bstype string) ([]PublicOrder, error) {
+	switch bstype {
+	case "buy":
+		return sortedSide(book.Asks, true, 0), nil
+	case "sell":
+		return sortedSide(book.Bids, false, 0), nil
+	}
+
+	return nil, fmt.Errorf("Invalid order type %s", bstype)
+}
+
+func (book PublicOrderBook) best() (float64, float64) {
+	asks := sortedSide(book.Asks, true, 1)
+	bids := sortedSide(book.Bids, false, 1)
+
+	if len(asks) == 0 || len(bids) == 0 {
+		return 0, 0
+	}
+
+	return bids[0].Price, asks[0].Price
+}
+
+// Sweep the book with a market order, until volume (base) or amount (quote) is filled.
+func (book PublicOrderBook) sweep(bstype string, volume, amount float64) (*SweepResult, error) {
+	levels, err := book.takenSide(bstype)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SweepResult{}
+
+	for _, level := range levels {
+		remaining := volume - result.Volume
+		if amount > 0 {
+			remaining = (amount - result.Cost) / level.Price
+		}
+
+		if remaining < VOLUME_EPSILON {
+			break
+		}
+
+		taken := math.Min(remaining, level.Volume)
+		result.Volume += taken
+		result.Cost += taken * level.Price
+		result.WorstPrice = level.Price
+		result.Levels++
+	}
+
+	if amount > 0 {
+		result.Complete = amount-result.Cost < VOLUME_EPSILON
+	} else {
+		result.Complete = volume-result.Volume < VOLUME_EPSILON
+	}
+
+	if result.Volume == 0 {
+		return result, nil
+	}
+
+	result.VWAP = result.Cost / result.Volume
+	result.Slippage = math.Abs(result.VWAP-levels[0].Price) / levels[0].Price
+
+	if mid := book.Mid(); mid != 0 {
+		result.SlippageFromMid = math.Abs(result.VWAP-mid) / mid
+	}
+
+	return result, nil
+}
+
+// Returns the outcome of a market order (buy/sell) of volume (base currency).
+func (book PublicOrderBook) Sweep(bstype string, volume float64) (*SweepResult, error) {
+	return book.sweep(bstype, volume, 0)
+}
+
+// Returns the outcome of a market order (buy/sell) spending or receiving amount (quote currency).
+func (book PublicOrderBook) SweepQuote(bstype string, amount float64) (*SweepResult, error) {
+	if amount <= 0 {
+		return &SweepResult{Complete: true}, nil
+	}
+
+	return book.sweep(bstype, 0, amount)
+}
+
+// Returns the mid price, 0 if a side is empty.
+func (book PublicOrderBook) Mid() float64 {
+	bid, ask := book.best()
+
+	return (bid + ask) / 2
+}
+
+// Returns the micro-price: the mid price weighted by the volumes at the best bid and ask,
+// closer to the ask when bids are heavier. 0 if a side is empty.
+func (book PublicOrderBook) MicroPrice() float64 {
+	asks := sortedSide(book.Asks, true, 1)
+	bids := sortedSide(book.Bids, false, 1)
+
+	if len(asks) == 0 || len(bids) == 0 {
+		return 0
+	}
+
+	ask, bid := asks[0], bids[0]
+
+	return (bid.Price*ask.Volume + ask.Price*bid.Volume) / (ask.Volume + bid.Volume)
+}
+
+// Returns the spread in basis points of the mid price, 0 if a side is empty.
+func (book PublicOrderBook) SpreadBps() float64 {
+	bid, ask := book.best()
+	if bid == 0 || ask == 0 {
+		return 0
+	}
+
+	return (ask - bid) / ((ask + bid) / 2) * 10000
+}
+
+// Returns the bid and ask volumes priced within band of the mid price (ie: 0.01 for 1%).
+func (book PublicOrderBook) DepthWithin(band float64) (float64, float64) {
+	mid := book.Mid()
+	if mid == 0 {
+		return 0, 0
+	}
+
+	bid_volume, ask_volume := 0.0, 0.0
+
+	for _, bid := range book.Bids {
+		if bid.Price >= mid*(1-band) {
+			bid_volume += bid.Volume
+		}
+	}
+
+	for _, ask := range book.Asks {
+		if ask.Price <= mid*(1+band) {
+			ask_volume += ask.Volume
+		}
+	}
+
+	return bid_volume, ask_volume
+}
+
+/*
+Returns the order book imbalance over the best levels of each side (0 for all levels):
+(bid volume - ask volume) / (bid volume + ask volume), from -1 (only asks) to 1 (only bids).
+*/
+func (book PublicOrderBook) Imbalance(levels int) float64 {
+	bid_volume, ask_volume := 0.0, 0.0
+
+	for _, bid := range sortedSide(book.Bids, false, levels) {
+		bid_volume += bid.Volume
+	}
+
+	for _, ask := range sortedSide(book.Asks, true, levels) {
+		ask_volume += ask.Volume
+	}
+
+	if bid_volume+ask_volume == 0 {
+		return 0
+	}
+
+	return (bid_volume - ask_volume) / (bid_volume + ask_volume)
+}
@@ -0,0 +1,85 @@
+package krakenapi
+
+import (
+	"math"
+	"testing"
+)
+
+// Unsorted, as returned by ApiDepth
+var testBook = PublicOrderBook{
+	Asks: []PublicOrder{{Price: 102, Volume: 2}, {Price: 101, Volume: 1}, {Price: 105, Volume: 5}},
+	Bids: []PublicOrder{{Price: 98, Volume: 2}, {Price: 99, Volume: 3}, {Price: 90, Volume: 10}},
+}
+
+func TestBookSweep(t *testing.T) {
+	result, err := testBook.Sweep("buy", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 @ 101 + 1 @ 102
+	if result.Volume != 2 || result.Cost != 203 || result.VWAP != 101.5 || result.WorstPrice != 102 || result.Levels != 2 || !result.Complete {
+		t.Errorf("Unexpected buy sweep %+v", result)
+	}
+
+	if math.Abs(result.Slippage-0.5/101) > 1e-12 || math.Abs(result.SlippageFromMid-1.5/100) > 1e-12 {
+		t.Errorf("Unexpected buy slippage %+v", result)
+	}
+
+	result, err = testBook.Sweep("sell", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Volume != 15 || result.Complete || result.WorstPrice != 90 {
+		t.Errorf("Unexpected sell sweep %+v", result)
+	}
+
+	result, err = testBook.SweepQuote("buy", 305)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 101 + 204 quote: 1 @ 101, 2 @ 102
+	if result.Volume != 3 || result.Cost != 305 || !result.Complete {
+		t.Errorf("Unexpected quote sweep %+v", result)
+	}
+
+	_, err = testBook.Sweep("short", 1)
+	if err == nil {
+		t.Error("Invalid order type should fail")
+	}
+}
+
+func TestBookStats(t *testing.T) {
+	if mid := testBook.Mid(); mid != 100 {
+		t.Errorf("Unexpected mid %f", mid)
+	}
+
+	// (99 * 1 + 101 * 3) / 4
+	if micro := testBook.MicroPrice(); micro != 100.5 {
+		t.Errorf("Unexpected micro-price %f", micro)
+	}
+
+	if bps := testBook.SpreadBps(); bps != 200 {
+		t.Errorf("Unexpected spread %f bps", bps)
+	}
+
+	bid_volume, ask_volume := testBook.DepthWithin(0.02)
+	if bid_volume != 5 || ask_volume != 3 {
+		t.Errorf("Unexpected depth within 2%%: %f %f", bid_volume, ask_volume)
+	}
+
+	if imbalance := testBook.Imbalance(1); imbalance != 0.5 {
+		t.Errorf("Unexpected imbalance %f", imbalance)
+	}
+
+	if imbalance := testBook.Imbalance(0); imbalance != (15.0-8)/23 {
+		t.Errorf("Unexpected imbalance %f", imbalance)
+	}
+
+	empty := PublicOrderBook{}
+	if empty.Mid() != 0 || empty.MicroPrice() != 0 || empty.SpreadBps() != 0 || empty.Imbalance(0) != 0 {
+		t.Error("Empty book stats should be 0")
+	}
+}
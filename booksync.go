@@ -0,0 +1,117 @@
+package krakenapi
+
+import (
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+)
+
+/*
+BookSync keeps an OrderBook in sync with the book channel of the WebSocket feed.
+
+Book updates have no sequence number: after each update, the CRC32 checksum of the
+local book is compared to Kraken's. When it does not match (ie: an update was
+missed), the book channel of the pair is subscribed again on Client, so that Kraken
+sends a new snapshot consistent with the following updates. Until that snapshot is
+applied, updates are ignored: a single resubscription is made per mismatch.
+*/
+type BookSync struct {
+	Book           *OrderBook
+	Client         *WSClient   // feed the book channel is subscribed on
+	PriceDecimals  int         // pair_decimals of the pair
+	VolumeDecimals int         // lot_decimals of the pair
+	OnResync       func(error) // called after each resubscription, with the error met sending it (optional)
+
+	resyncing bool
+}
+
+// Create a book synced with the book channel of pair, subscribed on c with depth levels.
+func (c *WSClient) NewBookSync(pair AssetPair, depth int) *BookSync {
+	return &BookSync{
+		Book:           NewOrderBook(pair.Wsname, depth),
+		Client:         c,
+		PriceDecimals:  pair.PairDecimals,
+		VolumeDecimals: pair.LotDecimals,
+	}
+}
+
+// Returns a level value as used by the checksum: without decimal point nor leading zeros.
+func checksumValue(value float64, decimals int) string {
+	formatted := strconv.FormatFloat(value, 'f', decimals, 64)
+	return strings.TrimLeft(strings.Replace(formatted, ".", "", 1), "0")
+}
+
+// Returns the checksum of the top 10 asks and bids of the book.
+func (s *BookSync) Checksum() string {
+	snapshot := s.Book.Snapshot()
+
+	var b strings.Builder
+
+	for _, side := range [][]PublicOrder{snapshot.Asks, snapshot.Bids} {
+		for i, level := range side {
+			if i == 10 {
+				break
+			}
+
+			b.WriteString(checksumValue(level.Price, s.PriceDecimals))
+			b.WriteString(checksumValue(level.Volume, s.VolumeDecimals))
+		}
+	}
+
+	return strconv.FormatUint(uint64(crc32.ChecksumIEEE([]byte(b.String()))), 10)
+}
+
+// Returns whether the book is waiting for a new snapshot after a checksum mismatch.
+func (s *BookSync) Resyncing() bool {
+	return s.resyncing
+}
+
+/*
+Apply a book message. Returns true if the checksum did not match and the book
+channel was subscribed again, with the error met subscribing.
+*/
+func (s *BookSync) Apply(update WSBook) (bool, error) {
+	if update.Snapshot {
+		s.Book.Load(PublicOrderBook{Asks: update.Asks, Bids: update.Bids})
+		s.resyncing = false
+		return false, nil
+	}
+
+	if s.resyncing {
+		return false, nil
+	}
+
+	s.Book.Update(update.Asks, update.Bids)
+
+	if update.Checksum == "" || update.Checksum == s.Checksum() {
+		return false, nil
+	}
+
+	err := s.Resync()
+	if s.OnResync != nil {
+		s.OnResync(err)
+	}
+
+	return true, err
+}
+
+// Subscribe again to the book channel of the pair, then ignore updates until the new snapshot.
+func (s *BookSync) Resync() error {
+	s.resyncing = true
+
+	subscription := WSSubscription{Name: "book", Depth: s.Book.Depth}
+	pairs := []string{s.Book.Pair}
+
+	err := s.Client.request("unsubscribe", subscription, pairs)
+	if err == nil {
+		err = s.Client.request("subscribe", subscription, pairs)
+	}
+
+	if err != nil {
+		// The book is reloaded anyway when the client reconnects and subscribes again
+		return fmt.Errorf("Could not resync %s book: %s", s.Book.Pair, err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package krakenapi
+
+import (
+	"context"
+	"encoding/json"
+	"hash/crc32"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestBookSync(t *testing.T) {
+	requests := make(chan wsRequest, 10)
+
+	server, url := createTestWSServer(t, func(conn *websocket.Conn, message []byte) {
+		var request wsRequest
+		json.Unmarshal(message, &request)
+		requests <- request
+	})
+	defer server.Close()
+
+	client := NewWSClient(url, 10)
+
+	err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	resyncs := 0
+
+	sync := client.NewBookSync(AssetPair{Altname: "XBTEUR", Wsname: "XBT/EUR", PairDecimals: 5, LotDecimals: 8}, 10)
+	sync.OnResync = func(err error) {
+		if err != nil {
+			t.Error(err)
+		}
+		resyncs++
+	}
+
+	resynced, err := sync.Apply(WSBook{Snapshot: true, Asks: []PublicOrder{{Price: 0.05005, Volume: 0.000005}}, Bids: []PublicOrder{{Price: 0.05, Volume: 0.000005}}})
+	if resynced || err != nil {
+		t.Fatalf("Snapshot should not be resynced: %v", err)
+	}
+
+	// 0.05005 0.00001000, 0.05000 0.00000500
+	checksum := strconv.FormatUint(uint64(crc32.ChecksumIEEE([]byte("500510005000500"))), 10)
+
+	resynced, err = sync.Apply(WSBook{Asks: []PublicOrder{{Price: 0.05005, Volume: 0.00001}}, Checksum: checksum})
+	if resynced || err != nil {
+		t.Fatalf("Update with a valid checksum should not be resynced: %v", err)
+	}
+
+	if sync.Checksum() != checksum {
+		t.Errorf("Unexpected checksum %s", sync.Checksum())
+	}
+
+	// A missed update: the checksum does not match
+	resynced, err = sync.Apply(WSBook{Bids: []PublicOrder{{Price: 0.04995, Volume: 0.000001}}, Checksum: checksum})
+	if !resynced || err != nil || !sync.Resyncing() {
+		t.Fatalf("Update with an invalid checksum should be resynced: %v", err)
+	}
+
+	timeout := time.After(time.Second)
+
+	for _, event := range []string{"unsubscribe", "subscribe"} {
+		select {
+		case request := <-requests:
+			if request.Event != event || request.Subscription.Name != "book" || request.Subscription.Depth != 10 || request.Pair[0] != "XBT/EUR" {
+				t.Errorf("Unexpected request %+v", request)
+			}
+		case <-timeout:
+			t.Fatalf("No %s request", event)
+		}
+	}
+
+	// Updates are ignored until the new snapshot
+	resynced, err = sync.Apply(WSBook{Bids: []PublicOrder{{Price: 0.04995, Volume: 0.000001}}, Checksum: checksum})
+	if resynced || err != nil || resyncs != 1 {
+		t.Fatalf("Updates should be ignored while resyncing: %v", err)
+	}
+
+	sync.Apply(WSBook{Snapshot: true, Asks: []PublicOrder{{Price: 0.0501, Volume: 0.000001}}, Bids: []PublicOrder{{Price: 0.0499, Volume: 0.000002}}})
+
+	ask, _ := sync.Book.BestAsk()
+	bid, _ := sync.Book.BestBid()
+	if sync.Resyncing() || ask.Price != 0.0501 || bid.Volume != 0.000002 {
+		t.Errorf("Book not reloaded: %v", sync.Book.Snapshot())
+	}
+}
@@ -0,0 +1,491 @@
+package krakenapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	BRACKET_ENTRY      = "entry"      // waiting for the entry order to fill
+	BRACKET_PROTECTING = "protecting" // take-profit & stop-loss legs are resting
+	BRACKET_DONE       = "done"       // one of the legs filled, the other was canceled
+	BRACKET_CANCELED   = "canceled"   // entry or legs were canceled without filling
+)
+
+/*
+A bracket order: an entry order, then a take-profit and a stop-loss order once
+the entry filled. When one leg fills, the other is canceled (one-cancels-other).
+Leg volumes are set from the executed volume of the entry.
+*/
+type Bracket struct {
+	Id             string       `json:"id"`
+	State          string       `json:"state"`
+	Entry          OrderRequest `json:"entry"`
+	TakeProfit     OrderRequest `json:"take_profit"`
+	StopLoss       OrderRequest `json:"stop_loss"`
+	EntryTxid      string       `json:"entry_txid"`
+	TakeProfitTxid string       `json:"take_profit_txid"`
+	StopLossTxid   string       `json:"stop_loss_txid"`
+	Created        time.Time    `json:"created"`
+}
+
+func (b *Bracket) active() bool {
+	return b.State == BRACKET_ENTRY || b.State == BRACKET_PROTECTING
+}
+
+// Persistence of brackets, so that a restarted process can resume managing them.
+type BracketStore interface {
+	Load() ([]*Bracket, error)
+	Save(brackets []*Bracket) error
+}
+
+// A BracketStore keeping brackets as json in a file.
+type FileBracketStore struct {
+	Path string
+}
+
+func (s *FileBracketStore) Load() ([]*Bracket, error) {
+	content, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var brackets []*Bracket
+
+	err = json.Unmarshal(content, &brackets)
+	if err != nil {
+		return nil, err
+	}
+
+	return brackets, nil
+}
+
+func (s *FileBracketStore) Save(brackets []*Bracket) error {
+	content, err := json.MarshalIndent(brackets, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.Path, content)
+}
+
+// Write then rename, so that a crash never leaves a truncated file.
+func writeFileAtomic(path string, content []byte) error {
+	err := ioutil.WriteFile(path+".tmp", content, 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// BracketManager places bracket orders and moves them forward on each Update.
+type BracketManager struct {
+	api   *KrakenApi
+	store BracketStore
+
+	mutex    sync.Mutex
+	brackets map[string]*Bracket
+}
+
+// Create a bracket manager, loading the brackets kept in store.
+// Call Reconcile after a restart to check them against the exchange.
+func (api *KrakenApi) NewBracketManager(store BracketStore) (*BracketManager, error) {
+	brackets, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &BracketManager{
+		api:      api,
+		store:    store,
+		brackets: make(map[string]*Bracket),
+	}
+
+	for _, bracket := range brackets {
+		m.brackets[bracket.Id] = bracket
+	}
+
+	return m, nil
+}
+
+func (m *BracketManager) save() error {
+	brackets := make([]*Bracket, 0, len(m.brackets))
+	for _, bracket := range m.brackets {
+		brackets = append(brackets, bracket)
+	}
+
+	return m.store.Save(brackets)
+}
+
+// Returns a copy of the managed brackets.
+func (m *BracketManager) Brackets() []Bracket {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	out := make([]Bracket, 0, len(m.brackets))
+	for _, bracket := range m.brackets {
+		out = append(out, *bracket)
+	}
+
+	return out
+}
+
+/*
+Place the entry order of a new bracket.
+
+take_profit and stop_loss describe the legs placed once the entry filled: their
+pair defaults to the entry's, their type to the opposite of the entry's, and
+their volume is set from the entry's executed volume.
+*/
+func (m *BracketManager) Place(entry, take_profit, stop_loss OrderRequest) (*Bracket, error) {
+	for _, leg := range []*OrderRequest{&take_profit, &stop_loss} {
+		if leg.Pair == "" {
+			leg.Pair = entry.Pair
+		}
+
+		if leg.Type == "" {
+			leg.Type = "sell"
+			if entry.Type == "sell" {
+				leg.Type = "buy"
+			}
+		}
+	}
+
+	if entry.ClOrdId == "" && entry.Userref == "" {
+		entry.ClOrdId = NewClOrdId()
+	}
+
+	bracket := &Bracket{
+		Id:         entry.ClOrdId,
+		State:      BRACKET_ENTRY,
+		Entry:      entry,
+		TakeProfit: take_profit,
+		StopLoss:   stop_loss,
+		Created:    time.Now(),
+	}
+	if bracket.Id == "" {
+		bracket.Id = NewClOrdId()
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// Save before submitting: the entry can be found back by its tag after a crash.
+	m.brackets[bracket.Id] = bracket
+
+	err := m.save()
+	if err != nil {
+		delete(m.brackets, bracket.Id)
+		return nil, err
+	}
+
+	result, err := m.api.SubmitOrder(&bracket.Entry)
+	if err != nil {
+		if _, ok := err.(*OrderNotPlacedError); ok {
+			delete(m.brackets, bracket.Id)
+			m.save()
+		}
+
+		return nil, err
+	}
+
+	bracket.EntryTxid = result.Txid[0]
+
+	out := *bracket
+
+	return &out, m.save()
+}
+
+// Query the orders of all active brackets, place legs of filled entries, and
+// cancel the remaining leg of brackets where a leg filled.
+func (m *BracketManager) Update() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	txids := make([]string, 0)
+	for _, bracket := range m.brackets {
+		if !bracket.active() {
+			continue
+		}
+
+		for _, txid := range []string{bracket.EntryTxid, bracket.TakeProfitTxid, bracket.StopLossTxid} {
+			if txid != "" {
+				txids = append(txids, txid)
+			}
+		}
+	}
+
+	orders, err := m.api.queryOrders(txids)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, bracket := range m.brackets {
+		if !bracket.active() {
+			continue
+		}
+
+		var err error
+
+		switch bracket.State {
+		case BRACKET_ENTRY:
+			err = m.updateEntry(bracket, orders)
+		case BRACKET_PROTECTING:
+			err = m.updateLegs(bracket, orders)
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("bracket %s: %s", bracket.Id, err))
+		}
+	}
+
+	err = m.save()
+	if err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("Could not update brackets! (%v)", errs)
+	}
+
+	return nil
+}
+
+func (m *BracketManager) updateEntry(bracket *Bracket, orders map[string]Order) error {
+	entry, ok := orders[bracket.EntryTxid]
+	if !ok || !isFinalStatus(entry.Status) {
+		return nil
+	}
+
+	if entry.VolExec == 0 {
+		bracket.State = BRACKET_CANCELED
+		return nil
+	}
+
+	bracket.TakeProfit.Volume = entry.VolExec
+	bracket.StopLoss.Volume = entry.VolExec
+	bracket.State = BRACKET_PROTECTING
+
+	return m.placeLegs(bracket)
+}
+
+// Place legs that were not placed yet.
+func (m *BracketManager) placeLegs(bracket *Bracket) error {
+	legs := []struct {
+		order *OrderRequest
+		txid  *string
+	}{
+		{&bracket.TakeProfit, &bracket.TakeProfitTxid},
+		{&bracket.StopLoss, &bracket.StopLossTxid},
+	}
+
+	for _, leg := range legs {
+		if *leg.txid != "" {
+			continue
+		}
+
+		if leg.order.ClOrdId == "" && leg.order.Userref == "" {
+			leg.order.ClOrdId = NewClOrdId()
+
+			// Persist the tag before submitting, to find the leg back after a crash.
+			err := m.save()
+			if err != nil {
+				return err
+			}
+		} else {
+			// A previous attempt may have placed the order without us knowing.
+			txid, found, err := m.api.findSubmittedOrder(leg.order, bracket.Created)
+			if err != nil {
+				return err
+			}
+
+			if found {
+				*leg.txid = txid
+				continue
+			}
+		}
+
+		result, err := m.api.SubmitOrder(leg.order)
+		if err != nil {
+			if _, ok := err.(*OrderNotPlacedError); ok {
+				leg.order.ClOrdId = ""
+			}
+
+			return err
+		}
+
+		*leg.txid = result.Txid[0]
+	}
+
+	return nil
+}
+
+func (m *BracketManager) updateLegs(bracket *Bracket, orders map[string]Order) error {
+	// Retry placing legs which failed to be placed.
+	if bracket.TakeProfitTxid == "" || bracket.StopLossTxid == "" {
+		return m.placeLegs(bracket)
+	}
+
+	take_profit, ok := orders[bracket.TakeProfitTxid]
+	if !ok {
+		return nil
+	}
+
+	stop_loss, ok := orders[bracket.StopLossTxid]
+	if !ok {
+		return nil
+	}
+
+	// Both legs are done, ie: both filled at once, or canceled by hand.
+	if isFinalStatus(take_profit.Status) && isFinalStatus(stop_loss.Status) {
+		bracket.State = BRACKET_CANCELED
+		if take_profit.VolExec > 0 || stop_loss.VolExec > 0 {
+			bracket.State = BRACKET_DONE
+		}
+
+		return nil
+	}
+
+	legs := [2]struct {
+		txid  string
+		order Order
+	}{
+		{bracket.TakeProfitTxid, take_profit},
+		{bracket.StopLossTxid, stop_loss},
+	}
+
+	for i, leg := range legs {
+		if isFinalStatus(leg.order.Status) {
+			_, err := m.api.ApiCancelOrder(legs[1-i].txid)
+			if err != nil {
+				return err
+			}
+
+			bracket.State = BRACKET_DONE
+			if leg.order.VolExec == 0 {
+				bracket.State = BRACKET_CANCELED
+			}
+
+			return nil
+		}
+	}
+
+	// Partial fills: what is left of the position to close.
+	left := bracket.TakeProfit.Volume - take_profit.VolExec - stop_loss.VolExec
+
+	if left <= 0 {
+		for _, leg := range legs {
+			_, err := m.api.ApiCancelOrder(leg.txid)
+			if err != nil {
+				return err
+			}
+		}
+
+		bracket.State = BRACKET_DONE
+
+		return nil
+	}
+
+	// Keep the open volume of each leg at what is left. The new volume of a leg only
+	// depends on its own executed volume, so both legs can be amended from the same query.
+	for _, leg := range legs {
+		if leg.order.Vol-leg.order.VolExec <= left {
+			continue
+		}
+
+		// AmendOrder sets the total volume of the order, executed volume included.
+		_, err := m.api.ApiAmendOrder(leg.txid, &OrderRequest{
+			OrderType: leg.order.Descr.Ordertype,
+			Volume:    leg.order.VolExec + left,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+Check brackets loaded from the store against the exchange, after a restart.
+
+Orders submitted right before a crash have no txid yet: they are searched by
+their client order id or user reference id in ApiOpenOrders then ApiClosedOrders.
+Orders which are not found are considered not placed, and are placed again by
+Update for legs. Brackets are then updated.
+*/
+func (m *BracketManager) Reconcile() error {
+	m.mutex.Lock()
+
+	open, err := m.api.ApiOpenOrders(false, "")
+	if err != nil {
+		m.mutex.Unlock()
+		return err
+	}
+
+	by_tag := make(map[string]string)
+	for txid, order := range open.Open {
+		if order.ClOrdId != "" {
+			by_tag[order.ClOrdId] = txid
+		}
+	}
+
+	for _, bracket := range m.brackets {
+		if !bracket.active() {
+			continue
+		}
+
+		legs := []struct {
+			order *OrderRequest
+			txid  *string
+		}{
+			{&bracket.Entry, &bracket.EntryTxid},
+			{&bracket.TakeProfit, &bracket.TakeProfitTxid},
+			{&bracket.StopLoss, &bracket.StopLossTxid},
+		}
+
+		for i, leg := range legs {
+			if *leg.txid != "" || (leg.order.ClOrdId == "" && leg.order.Userref == "") {
+				continue
+			}
+
+			// userrefs are not unique: those orders are matched by findSubmittedOrder.
+			if txid, ok := by_tag[leg.order.ClOrdId]; ok && leg.order.ClOrdId != "" {
+				*leg.txid = txid
+				continue
+			}
+
+			txid, found, err := m.api.findSubmittedOrder(leg.order, bracket.Created)
+			if err != nil {
+				m.mutex.Unlock()
+				return err
+			}
+
+			if found {
+				*leg.txid = txid
+			} else if i == 0 {
+				bracket.State = BRACKET_CANCELED
+			} else {
+				leg.order.ClOrdId = ""
+			}
+		}
+	}
+
+	err = m.save()
+	m.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return m.Update()
+}
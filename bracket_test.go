@@ -0,0 +1,249 @@
+package krakenapi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A fake exchange keeping orders in memory. QueryOrders returns all orders.
+type fakeExchange struct {
+	mutex  sync.Mutex
+	orders map[string]string // txid -> order json fields
+	amends map[string]string // txid -> last amended order_qty
+	next   int
+}
+
+func (e *fakeExchange) handler(w http.ResponseWriter, r *http.Request) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	r.ParseForm()
+
+	switch r.URL.Path {
+	case URL_PRIVATE_ADD_ORDER:
+		e.next++
+		txid := fmt.Sprintf("O%d", e.next)
+		e.orders[txid] = fmt.Sprintf(`"status":"open","vol":"%s","vol_exec":"0","cl_ord_id":"%s","userref":"%s","opentm":%d,"descr":{"type":"%s","ordertype":"%s"}`,
+			r.Form.Get("volume"), r.Form.Get("cl_ord_id"), r.Form.Get("userref"), time.Now().Unix(), r.Form.Get("type"), r.Form.Get("ordertype"))
+		fmt.Fprintf(w, `{"error":[],"result":{"txid":["%s"]}}`, txid)
+	case URL_PRIVATE_AMEND_ORDER:
+		e.amends[r.Form.Get("txid")] = r.Form.Get("order_qty")
+		fmt.Fprint(w, `{"error":[],"result":{"amend_id":"A1"}}`)
+	case URL_PRIVATE_CANCEL_ORDER:
+		txid := r.Form.Get("txid")
+		e.orders[txid] = `"status":"canceled","vol":"1","vol_exec":"0"`
+		fmt.Fprint(w, `{"error":[],"result":{"count":1}}`)
+	case URL_PRIVATE_QUERY_ORDERS:
+		out := ""
+		for txid, order := range e.orders {
+			if out != "" {
+				out += ","
+			}
+			out += fmt.Sprintf(`"%s":{%s}`, txid, order)
+		}
+		fmt.Fprintf(w, `{"error":[],"result":{%s}}`, out)
+	case URL_PRIVATE_OPEN_ORDERS:
+		out := ""
+		for txid, order := range e.orders {
+			if !strings.HasPrefix(order, `"status":"open"`) {
+				continue
+			}
+			if out != "" {
+				out += ","
+			}
+			out += fmt.Sprintf(`"%s":{%s}`, txid, order)
+		}
+		fmt.Fprintf(w, `{"error":[],"result":{"open":{%s}}}`, out)
+	case URL_PRIVATE_CLOSED_ORDERS:
+		fmt.Fprint(w, `{"error":[],"result":{"closed":{},"count":0}}`)
+	}
+}
+
+func (e *fakeExchange) set(txid, order string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.orders[txid] = order
+}
+
+func TestBracketManager(t *testing.T) {
+	exchange := &fakeExchange{orders: make(map[string]string), amends: make(map[string]string)}
+
+	client, server := createTestApiClient(exchange.handler)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "brackets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := &FileBracketStore{Path: filepath.Join(dir, "brackets.json")}
+
+	manager, err := client.NewBracketManager(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bracket, err := manager.Place(
+		OrderRequest{Pair: "XXBTZEUR", Type: "buy", OrderType: "limit", Price: 100, Volume: 1},
+		OrderRequest{OrderType: "limit", Price: 110},
+		OrderRequest{OrderType: "stop-loss", Price: 90},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exchange.set(bracket.EntryTxid, `"status":"closed","vol":"1","vol_exec":"1"`)
+
+	// Restart: brackets are loaded back from the store.
+	manager, err = client.NewBracketManager(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = manager.Reconcile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	brackets := manager.Brackets()
+	if len(brackets) != 1 || brackets[0].State != BRACKET_PROTECTING {
+		t.Fatalf("Unexpected brackets: %v", brackets)
+	}
+
+	bracket = &brackets[0]
+	if bracket.TakeProfit.Type != "sell" || bracket.TakeProfit.Volume != 1 || bracket.StopLossTxid == "" {
+		t.Fatalf("Unexpected legs: %v", bracket)
+	}
+
+	exchange.set(bracket.TakeProfitTxid, `"status":"closed","vol":"1","vol_exec":"1"`)
+
+	err = manager.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	brackets = manager.Brackets()
+	if brackets[0].State != BRACKET_DONE {
+		t.Errorf("Unexpected bracket state %s", brackets[0].State)
+	}
+
+	if exchange.orders[bracket.StopLossTxid][:20] != `"status":"canceled",` {
+		t.Errorf("Stop loss leg was not canceled: %s", exchange.orders[bracket.StopLossTxid])
+	}
+}
+
+func TestBracketPartialFills(t *testing.T) {
+	exchange := &fakeExchange{orders: make(map[string]string), amends: make(map[string]string)}
+
+	client, server := createTestApiClient(exchange.handler)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "brackets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	manager, err := client.NewBracketManager(&FileBracketStore{Path: filepath.Join(dir, "brackets.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bracket, err := manager.Place(
+		OrderRequest{Pair: "XXBTZEUR", Type: "buy", OrderType: "limit", Price: 100, Volume: 10},
+		OrderRequest{OrderType: "limit", Price: 110},
+		OrderRequest{OrderType: "stop-loss", Price: 90},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exchange.set(bracket.EntryTxid, `"status":"closed","vol":"10","vol_exec":"10"`)
+
+	err = manager.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bracket = &manager.Brackets()[0]
+
+	// 4 of 10 closed by the take-profit, 1 by the stop-loss: 5 are left to close
+	exchange.set(bracket.TakeProfitTxid, `"status":"open","vol":"10","vol_exec":"4","descr":{"ordertype":"limit"}`)
+	exchange.set(bracket.StopLossTxid, `"status":"open","vol":"10","vol_exec":"1","descr":{"ordertype":"stop-loss"}`)
+
+	err = manager.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exchange.amends[bracket.TakeProfitTxid] != "9" || exchange.amends[bracket.StopLossTxid] != "6" {
+		t.Errorf("Unexpected amends: %v", exchange.amends)
+	}
+
+	// Both legs closed the whole position
+	exchange.set(bracket.TakeProfitTxid, `"status":"open","vol":"9","vol_exec":"6","descr":{"ordertype":"limit"}`)
+	exchange.set(bracket.StopLossTxid, `"status":"open","vol":"6","vol_exec":"4","descr":{"ordertype":"stop-loss"}`)
+
+	err = manager.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state := manager.Brackets()[0].State; state != BRACKET_DONE {
+		t.Errorf("Unexpected bracket state %s", state)
+	}
+
+	for _, txid := range []string{bracket.TakeProfitTxid, bracket.StopLossTxid} {
+		if !strings.HasPrefix(exchange.orders[txid], `"status":"canceled"`) {
+			t.Errorf("Leg %s was not canceled: %s", txid, exchange.orders[txid])
+		}
+	}
+}
+
+func TestBracketReconcileUserref(t *testing.T) {
+	exchange := &fakeExchange{orders: make(map[string]string), amends: make(map[string]string)}
+
+	client, server := createTestApiClient(exchange.handler)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "brackets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := &FileBracketStore{Path: filepath.Join(dir, "brackets.json")}
+
+	// The process crashed after submitting the entry, before saving its txid
+	entry := OrderRequest{Pair: "XXBTZEUR", Type: "buy", OrderType: "limit", Price: 100, Volume: 1, Userref: "7"}
+	err = store.Save([]*Bracket{{Id: "b", State: BRACKET_ENTRY, Entry: entry, Created: time.Now()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exchange.set("OENTRY", fmt.Sprintf(`"status":"open","vol":"1","vol_exec":"0","userref":7,"opentm":%d,"descr":{"type":"buy","ordertype":"limit"}`, time.Now().Unix()))
+
+	manager, err := client.NewBracketManager(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = manager.Reconcile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	brackets := manager.Brackets()
+	if brackets[0].EntryTxid != "OENTRY" || brackets[0].State != BRACKET_ENTRY {
+		t.Errorf("Entry not reconciled: %+v", brackets[0])
+	}
+}
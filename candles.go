@@ -0,0 +1,205 @@
+package krakenapi
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Merge a later candle into bar: bar keeps its time and open, VWAP is volume weighted.
+func (bar *OHLCEntry) merge(candle OHLCEntry) {
+	bar.High = math.Max(bar.High, candle.High)
+	bar.Low = math.Min(bar.Low, candle.Low)
+	bar.Close = candle.Close
+
+	if bar.Volume+candle.Volume > 0 {
+		bar.VWAP = (bar.VWAP*bar.Volume + candle.VWAP*candle.Volume) / (bar.Volume + candle.Volume)
+	}
+
+	bar.Volume += candle.Volume
+	bar.Count += candle.Count
+}
+
+/*
+Aggregate candles of the from interval into candles of the to interval, which
+must be a multiple of from (ie: 1h candles into 2h candles, 1d candles into 3d
+candles). Candles are aligned on multiples of to since the unix epoch.
+
+VWAP is the volume weighted average of the candles' VWAP, counts are summed.
+An aggregated candle is committed when all its candles are committed, the first
+one starts with it and the last one ends with it.
+*/
+func ResampleOHLC(entries []OHLCEntry, from OHLCInterval, to time.Duration) ([]OHLCEntry, error) {
+	step := from.Duration()
+	if step <= 0 || to < step || to%step != 0 {
+		return nil, fmt.Errorf("Can not resample %s candles into %s candles", step, to)
+	}
+
+	sorted := make([]OHLCEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Time < sorted[j].Time })
+
+	size := to.Seconds()
+	resampled := []OHLCEntry{}
+
+	for _, entry := range sorted {
+		start := math.Floor(entry.Time/size) * size
+
+		if len(resampled) == 0 || resampled[len(resampled)-1].Time != start {
+			entry.Time = start
+			resampled = append(resampled, entry)
+			continue
+		}
+
+		bar := &resampled[len(resampled)-1]
+		bar.merge(entry)
+		bar.Committed = bar.Committed && entry.Committed
+	}
+
+	if len(resampled) > 0 {
+		// Kraken only returns the last 720 candles, which may begin partway into a bar
+		first := &resampled[0]
+		first.Committed = first.Committed && sorted[0].Time <= first.Time
+
+		last := &resampled[len(resampled)-1]
+		end := sorted[len(sorted)-1].Time + step.Seconds()
+		last.Committed = last.Committed && end >= last.Time+size
+	}
+
+	return resampled, nil
+}
+
+// Bar sizing method of a BarBuilder.
+type BarType int
+
+const (
+	BAR_TIME   BarType = iota // fixed duration bars, aligned on multiples of the duration since the unix epoch
+	BAR_VOLUME                // bars of a fixed base volume
+	BAR_TICK                  // bars of a fixed number of trades
+)
+
+/*
+BarBuilder builds candles of any size from trades, as returned by ApiTrades.
+
+Trades must be added in time order. Time bars are only committed when a trade of
+a later bar arrives; intervals without trades produce no bar. Trades crossing the
+size of a volume bar are split between bars, and counted in each of them.
+*/
+type BarBuilder struct {
+	Type BarType
+	Size float64 // seconds, base volume or number of trades, depending on Type
+
+	current *OHLCEntry
+}
+
+func NewTimeBarBuilder(duration time.Duration) *BarBuilder {
+	return &BarBuilder{Type: BAR_TIME, Size: duration.Seconds()}
+}
+
+func NewVolumeBarBuilder(volume float64) *BarBuilder {
+	return &BarBuilder{Type: BAR_VOLUME, Size: volume}
+}
+
+func NewTickBarBuilder(trades int) *BarBuilder {
+	return &BarBuilder{Type: BAR_TICK, Size: float64(trades)}
+}
+
+func tradeCandle(trade RecentTrade, volume float64) OHLCEntry {
+	return OHLCEntry{
+		Time:   trade.Time,
+		Open:   trade.Price,
+		High:   trade.Price,
+		Low:    trade.Price,
+		Close:  trade.Price,
+		VWAP:   trade.Price,
+		Volume: volume,
+		Count:  1,
+	}
+}
+
+// Returns the bar being built, if any. It is not committed.
+func (b *BarBuilder) Current() (OHLCEntry, bool) {
+	if b.current == nil {
+		return OHLCEntry{}, false
+	}
+
+	return *b.current, true
+}
+
+// Add a trade, returning the bars it completed. Builders without a Size ignore trades.
+func (b *BarBuilder) Add(trade RecentTrade) []OHLCEntry {
+	completed := []OHLCEntry{}
+
+	if b.Size <= 0 {
+		return completed
+	}
+
+	switch b.Type {
+	case BAR_TIME:
+		start := math.Floor(trade.Time/b.Size) * b.Size
+
+		if b.current != nil && b.current.Time != start {
+			completed = append(completed, b.commit())
+		}
+
+		b.add(trade, trade.Volume, start)
+
+	case BAR_VOLUME:
+		volume := trade.Volume
+
+		for volume > VOLUME_EPSILON {
+			part := math.Min(volume, b.Size)
+			if b.current != nil {
+				part = math.Min(volume, b.Size-b.current.Volume)
+			}
+
+			b.add(trade, part, trade.Time)
+			volume -= part
+
+			if b.current.Volume >= b.Size-VOLUME_EPSILON {
+				completed = append(completed, b.commit())
+			}
+		}
+
+	case BAR_TICK:
+		b.add(trade, trade.Volume, trade.Time)
+
+		if b.current.Count >= b.Size {
+			completed = append(completed, b.commit())
+		}
+	}
+
+	return completed
+}
+
+// Add trades, returning the bars they completed.
+func (b *BarBuilder) AddTrades(trades []RecentTrade) []OHLCEntry {
+	completed := []OHLCEntry{}
+
+	for _, trade := range trades {
+		completed = append(completed, b.Add(trade)...)
+	}
+
+	return completed
+}
+
+func (b *BarBuilder) add(trade RecentTrade, volume float64, start float64) {
+	candle := tradeCandle(trade, volume)
+
+	if b.current == nil {
+		candle.Time = start
+		b.current = &candle
+		return
+	}
+
+	b.current.merge(candle)
+}
+
+func (b *BarBuilder) commit() OHLCEntry {
+	bar := *b.current
+	bar.Committed = true
+	b.current = nil
+
+	return bar
+}
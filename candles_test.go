@@ -0,0 +1,87 @@
+package krakenapi
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestResampleOHLC(t *testing.T) {
+	entries := []OHLCEntry{
+		{Time: 7200, Open: 10, High: 12, Low: 9, Close: 11, VWAP: 10, Volume: 1, Count: 2, Committed: true},
+		{Time: 10800, Open: 11, High: 15, Low: 10, Close: 14, VWAP: 13, Volume: 3, Count: 5, Committed: true},
+		{Time: 14400, Open: 14, High: 14, Low: 8, Close: 9, VWAP: 12, Volume: 2, Count: 1, Committed: false},
+	}
+
+	resampled, err := ResampleOHLC(entries, OHLC_1H, 2*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resampled) != 2 {
+		t.Fatalf("Unexpected candles %v", resampled)
+	}
+
+	first := resampled[0]
+	if first.Time != 7200 || first.Open != 10 || first.High != 15 || first.Low != 9 || first.Close != 14 || first.Volume != 4 || first.Count != 7 || !first.Committed {
+		t.Errorf("Unexpected first candle %v", first)
+	}
+
+	if math.Abs(first.VWAP-(10*1+13*3)/4.0) > 1e-9 {
+		t.Errorf("Unexpected VWAP %f", first.VWAP)
+	}
+
+	// last candle is incomplete: 14400 to 21600 with only one hour
+	if resampled[1].Time != 14400 || resampled[1].Committed {
+		t.Errorf("Unexpected last candle %v", resampled[1])
+	}
+
+	// first candle is incomplete: 7200 to 14400 starting at 10800
+	resampled, err = ResampleOHLC(entries[1:], OHLC_1H, 2*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resampled) != 2 || resampled[0].Time != 7200 || resampled[0].Open != 11 || resampled[0].Volume != 3 || resampled[0].Committed {
+		t.Errorf("Unexpected first candle %v", resampled)
+	}
+
+	_, err = ResampleOHLC(entries, OHLC_1H, 90*time.Minute)
+	if err == nil {
+		t.Error("90m is not a multiple of 1h")
+	}
+}
+
+func TestBarBuilder(t *testing.T) {
+	trades := []RecentTrade{
+		{Price: 10, Volume: 1, Time: 70},
+		{Price: 12, Volume: 2, Time: 110},
+		{Price: 11, Volume: 1.5, Time: 190},
+		{Price: 9, Volume: 0.5, Time: 250},
+	}
+
+	bars := NewTimeBarBuilder(time.Minute).AddTrades(trades)
+	if len(bars) != 2 || bars[0].Time != 60 || bars[0].Volume != 3 || bars[0].High != 12 || bars[1].Time != 180 || bars[1].Count != 1 {
+		t.Errorf("Unexpected time bars %v", bars)
+	}
+
+	builder := NewVolumeBarBuilder(2)
+	bars = builder.AddTrades(trades)
+	if len(bars) != 2 || bars[0].Volume != 2 || bars[0].Count != 2 || bars[1].Volume != 2 || bars[1].Open != 12 || bars[1].Close != 11 {
+		t.Errorf("Unexpected volume bars %v", bars)
+	}
+
+	if math.Abs(bars[0].VWAP-11) > 1e-9 {
+		t.Errorf("Unexpected volume bar VWAP %f", bars[0].VWAP)
+	}
+
+	current, ok := builder.Current()
+	if !ok || current.Volume != 1 || current.Close != 9 || current.Committed {
+		t.Errorf("Unexpected current volume bar %v", current)
+	}
+
+	bars = NewTickBarBuilder(3).AddTrades(trades)
+	if len(bars) != 1 || bars[0].Count != 3 || bars[0].Volume != 4.5 || bars[0].Low != 10 || !bars[0].Committed {
+		t.Errorf("Unexpected tick bars %v", bars)
+	}
+}
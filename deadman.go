@@ -0,0 +1,130 @@
+package krakenapi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Maximum time Stop waits for the switch to be disabled.
+var DeadManStopTimeout = 10 * time.Second
+
+// DeadManSwitch keeps re-arming CancelAllOrdersAfter from a goroutine, so that
+// Kraken cancels all open orders if the process dies or loses network.
+type DeadManSwitch struct {
+	api      *KrakenApi
+	Timeout  time.Duration               // orders get cancelled this long after the last successful re-arm, at least a second
+	Interval time.Duration               // delay between re-arms, must be lower than Timeout
+	OnError  func(err error)             // called from the goroutine when a re-arm fails (optional)
+	OnArm    func(*CancelAllAfterResult) // called from the goroutine after each re-arm (optional)
+
+	mutex sync.Mutex
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// Create a dead man's switch. It must be started with Start.
+func (api *KrakenApi) NewDeadManSwitch(timeout, interval time.Duration) *DeadManSwitch {
+	return &DeadManSwitch{
+		api:      api,
+		Timeout:  timeout,
+		Interval: interval,
+	}
+}
+
+// Arm the switch and start re-arming it every interval.
+// Fails if the first arming fails.
+func (d *DeadManSwitch) Start() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.stop != nil {
+		return fmt.Errorf("Dead man's switch already started")
+	}
+
+	// CancelAllOrdersAfter takes seconds, and 0 disables the timer
+	if d.Timeout < time.Second {
+		return fmt.Errorf("Invalid dead man's switch timeout %s: must be at least 1s", d.Timeout)
+	}
+
+	if d.Interval <= 0 || d.Interval >= d.Timeout {
+		return fmt.Errorf("Invalid dead man's switch interval %s for timeout %s", d.Interval, d.Timeout)
+	}
+
+	err := d.arm()
+	if err != nil {
+		return err
+	}
+
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+
+	go d.run(d.stop, d.done)
+
+	return nil
+}
+
+// A re-arm gives up after Interval, the next tick retrying it.
+func (d *DeadManSwitch) arm() error {
+	ctx, cancel := context.WithTimeout(context.Background(), d.Interval)
+	defer cancel()
+
+	result, err := d.api.cancelAllOrdersAfter(ctx, int(d.Timeout.Seconds()))
+	if err != nil {
+		return err
+	}
+
+	if d.OnArm != nil {
+		d.OnArm(result)
+	}
+
+	return nil
+}
+
+func (d *DeadManSwitch) run(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			err := d.arm()
+			if err != nil && d.OnError != nil {
+				d.OnError(err)
+			}
+		}
+	}
+}
+
+// Stop re-arming the switch and disable the timer, so open orders are left untouched.
+// Waits at most Interval for an in-flight re-arm, then returns an error if the timer
+// could not be disabled within DeadManStopTimeout.
+func (d *DeadManSwitch) Stop() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.stop == nil {
+		return nil
+	}
+
+	close(d.stop)
+	<-d.done
+
+	d.stop = nil
+	d.done = nil
+
+	ctx, cancel := context.WithTimeout(context.Background(), DeadManStopTimeout)
+	defer cancel()
+
+	_, err := d.api.cancelAllOrdersAfter(ctx, 0)
+	if err != nil {
+		return fmt.Errorf("Could not disable dead man's switch (%s)", err)
+	}
+
+	return nil
+}
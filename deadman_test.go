@@ -0,0 +1,91 @@
+package krakenapi
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDeadManSwitchStop(t *testing.T) {
+	timeout := DeadManStopTimeout
+	DeadManStopTimeout = 50 * time.Millisecond
+	defer func() { DeadManStopTimeout = timeout }()
+
+	release := make(chan struct{})
+
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		// Disarming never gets an answer
+		if r.Form.Get("timeout") == "0" {
+			<-release
+			return
+		}
+
+		fmt.Fprint(w, `{"error":[],"result":{"currentTime":"2023-01-01T00:00:00Z","triggerTime":"2023-01-01T00:01:00Z"}}`)
+	})
+	defer server.Close()
+	defer close(release)
+
+	err := client.NewDeadManSwitch(500*time.Millisecond, 100*time.Millisecond).Start()
+	if err == nil {
+		t.Error("A timeout under a second should be refused")
+	}
+
+	dms := client.NewDeadManSwitch(60*time.Second, time.Second)
+
+	err = dms.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = dms.Stop()
+	if err == nil {
+		t.Error("Stop should give up when disarming hangs")
+	}
+}
+
+func TestDeadManSwitchStopDuringArm(t *testing.T) {
+	timeout := DeadManStopTimeout
+	DeadManStopTimeout = 50 * time.Millisecond
+	defer func() { DeadManStopTimeout = timeout }()
+
+	release := make(chan struct{})
+	calls := int32(0)
+
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		// Every re-arm after the first one hangs
+		if atomic.AddInt32(&calls, 1) > 1 {
+			<-release
+			return
+		}
+
+		fmt.Fprint(w, `{"error":[],"result":{"currentTime":"2023-01-01T00:00:00Z","triggerTime":"2023-01-01T00:01:00Z"}}`)
+	})
+	defer server.Close()
+	defer close(release)
+
+	dms := client.NewDeadManSwitch(60*time.Second, 50*time.Millisecond)
+
+	err := dms.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Let the goroutine get stuck in a re-arm
+	time.Sleep(80 * time.Millisecond)
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- dms.Stop() }()
+
+	select {
+	case err = <-stopped:
+		if err == nil {
+			t.Error("Stop should report the hanging disarm")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stop blocked on a hanging re-arm")
+	}
+}
@@ -0,0 +1,375 @@
+package krakenapi
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// State of an execution algorithm, as given to progress callbacks and returned once done.
+type ExecutionProgress struct {
+	Volume       float64  // total volume to execute
+	Executed     float64  // volume executed so far
+	Cost         float64  // total cost of executed volume (quote currency)
+	Orders       []string // txids of child orders
+	MarketVolume float64  // market volume traded since the start, if participation is capped
+	Done         bool     // whole volume executed
+}
+
+// Volumes below this are considered zero: Kraken volumes have at most 8 decimals.
+const VOLUME_EPSILON = 1e-9
+
+// Volume left to execute.
+func (p *ExecutionProgress) Remaining() float64 {
+	remaining := p.Volume - p.Executed
+	if remaining < VOLUME_EPSILON {
+		return 0
+	}
+
+	return remaining
+}
+
+// Average execution price so far.
+func (p *ExecutionProgress) AveragePrice() float64 {
+	if p.Executed == 0 {
+		return 0
+	}
+
+	return p.Cost / p.Executed
+}
+
+// Keeps track of the market volume since the start of an algorithm using ApiTrades.
+type participation struct {
+	api      *KrakenApi
+	pair     string
+	max      float64 // maximum fraction of the market volume, 0 for no cap
+	since    string
+	lastTime float64
+	volume   float64
+}
+
+func (p *participation) update() error {
+	if p.max == 0 {
+		return nil
+	}
+
+	trades, last, err := p.api.trades(p.pair, p.since)
+	if err != nil {
+		return err
+	}
+
+	max_time := p.lastTime
+
+	for _, pair_trades := range trades {
+		for _, trade := range pair_trades {
+			// Pages may overlap, only count trades newer than the previous page.
+			if trade.Time <= p.lastTime {
+				continue
+			}
+
+			p.volume += trade.Volume
+			max_time = math.Max(max_time, trade.Time)
+		}
+	}
+
+	p.lastTime = max_time
+	p.since = last
+
+	return nil
+}
+
+// Returns the volume that can still be executed without exceeding the cap.
+func (p *participation) allowed(executed float64) float64 {
+	if p.max == 0 {
+		return math.Inf(1)
+	}
+
+	return math.Max(0, p.max*p.volume-executed)
+}
+
+func newParticipation(api *KrakenApi, pair string, max float64) *participation {
+	return &participation{
+		api:  api,
+		pair: pair,
+		max:  max,
+		// Only count trades from now on.
+		since:    strconv.FormatInt(time.Now().UnixNano(), 10),
+		lastTime: float64(time.Now().Unix()),
+	}
+}
+
+// Returns the lot_decimals of pair: child volumes are rounded down to them.
+func (api *KrakenApi) lotDecimals(pair string) (int, error) {
+	pairs, err := api.ApiAssetPairs("", pair)
+	if err != nil {
+		return 0, fmt.Errorf("Could not get %s lot decimals: %s", pair, err.Error())
+	}
+
+	for _, asset_pair := range pairs {
+		return asset_pair.LotDecimals, nil
+	}
+
+	return 0, fmt.Errorf("Could not get %s lot decimals: unknown pair", pair)
+}
+
+// Round volume down to decimals.
+func floorVolume(volume float64, decimals int) float64 {
+	scale := math.Pow10(decimals)
+
+	// Tolerate binary representation errors (ie: 0.3 * 1e8 = 29999999.999999996)
+	return math.Floor(volume*scale+1e-6) / scale
+}
+
+// Returned by execution algorithms when the volume left cannot be placed.
+func unexecutableError(remaining, min_volume float64) error {
+	return fmt.Errorf("Could not execute the remaining volume %v: below the minimum volume %v or the pair's lot size", remaining, min_volume)
+}
+
+// Place a child order of an algorithm.
+func (api *KrakenApi) placeChild(parent *OrderRequest, volume float64, progress *ExecutionProgress) (string, error) {
+	child := *parent
+	child.Volume = volume
+	child.ClOrdId = ""
+
+	result, err := api.SubmitOrder(&child)
+	if err != nil {
+		return "", err
+	}
+
+	progress.Orders = append(progress.Orders, result.Txid[0])
+
+	return result.Txid[0], nil
+}
+
+// Cancel a child order if still open, then account for its executed volume.
+func (api *KrakenApi) settleChild(txid string, progress *ExecutionProgress) error {
+	order, err := api.queryOrder(txid)
+	if err != nil {
+		return err
+	}
+
+	if !isFinalStatus(order.Status) {
+		_, err = api.ApiCancelOrder(txid)
+		if err != nil {
+			return err
+		}
+
+		order, err = api.queryOrder(txid)
+		if err != nil {
+			return err
+		}
+	}
+
+	progress.Executed += order.VolExec
+	progress.Cost += order.Cost
+
+	return nil
+}
+
+type TWAPParams struct {
+	Order            OrderRequest            // parent order: pair, type, ordertype (market or limit), price, total volume
+	Duration         time.Duration           // time window to execute the order in
+	Slices           int                     // number of child orders
+	Randomness       float64                 // child sizes vary randomly by up to this fraction (ie: 0.2 for +/-20%)
+	MaxParticipation float64                 // maximum fraction of the market volume to execute, 0 for no cap
+	MinVolume        float64                 // minimum child volume (ie: pair's minimum order size)
+	OnProgress       func(ExecutionProgress) // called after each slice (optional)
+}
+
+/*
+Execute an order in slices spread over a time window (time-weighted average price).
+
+At each slice, the remaining volume is divided by the remaining slices, randomized,
+rounded down to the pair's lot decimals, then placed. Limit children left unfilled
+at the end of their slice are canceled and their volume is carried over to the next
+slices. A child leaving less than MinVolume to execute takes the whole remaining
+volume; if the volume left at the end cannot be placed, an error is returned.
+
+With MaxParticipation, no market volume has been seen yet at the first slice: it
+places nothing, and the volume is spread over the next slices.
+
+Cancelling ctx cancels the current child order and stops the execution.
+*/
+func (api *KrakenApi) TWAP(ctx context.Context, params TWAPParams) (*ExecutionProgress, error) {
+	if params.Slices <= 0 || params.Duration <= 0 {
+		return nil, fmt.Errorf("Invalid TWAP slices (%d) or duration (%s)", params.Slices, params.Duration)
+	}
+
+	lot_decimals, err := api.lotDecimals(params.Order.Pair)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &ExecutionProgress{Volume: params.Order.Volume}
+	market := newParticipation(api, params.Order.Pair, params.MaxParticipation)
+	interval := params.Duration / time.Duration(params.Slices)
+
+	for slice := 0; slice < params.Slices; slice++ {
+		remaining := progress.Remaining()
+
+		err := market.update()
+		if err != nil {
+			return progress, err
+		}
+
+		volume := remaining / float64(params.Slices-slice)
+		if slice < params.Slices-1 {
+			volume *= 1 + params.Randomness*(2*rand.Float64()-1)
+		}
+		volume = math.Min(volume, remaining)
+
+		allowed := market.allowed(progress.Executed)
+		if remaining-volume < params.MinVolume && remaining <= allowed {
+			volume = remaining
+		}
+
+		volume = floorVolume(math.Min(volume, allowed), lot_decimals)
+		progress.MarketVolume = market.volume
+
+		var txid string
+		if volume > 0 && volume >= params.MinVolume {
+			txid, err = api.placeChild(&params.Order, volume, progress)
+			if err != nil {
+				return progress, err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if txid != "" {
+				api.settleChild(txid, progress)
+			}
+			return progress, ctx.Err()
+		case <-time.After(interval):
+		}
+
+		if txid != "" {
+			err = api.settleChild(txid, progress)
+			if err != nil {
+				return progress, err
+			}
+		}
+
+		progress.Done = progress.Remaining() == 0
+
+		if params.OnProgress != nil {
+			params.OnProgress(*progress)
+		}
+
+		if progress.Done {
+			break
+		}
+	}
+
+	if remaining := progress.Remaining(); remaining > 0 && (remaining < params.MinVolume || floorVolume(remaining, lot_decimals) == 0) {
+		return progress, unexecutableError(remaining, params.MinVolume)
+	}
+
+	return progress, nil
+}
+
+type IcebergParams struct {
+	Order            OrderRequest            // parent limit order, with its total volume
+	DisplayVolume    float64                 // volume of each visible child order
+	Interval         time.Duration           // delay between child order checks
+	MaxParticipation float64                 // maximum fraction of the market volume to execute, 0 for no cap
+	MinVolume        float64                 // minimum child volume (ie: pair's minimum order size)
+	OnProgress       func(ExecutionProgress) // called each time a child order is done (optional)
+}
+
+/*
+Execute an order by only showing DisplayVolume at a time: a new child order is
+placed each time the previous one filled, until the whole volume is executed.
+Child volumes are rounded down to the pair's lot decimals. A child leaving less
+than MinVolume to execute takes the whole remaining volume; if the volume left
+cannot be placed, an error is returned.
+Cancelling ctx cancels the current child order and stops the execution.
+*/
+func (api *KrakenApi) Iceberg(ctx context.Context, params IcebergParams) (*ExecutionProgress, error) {
+	if params.DisplayVolume <= 0 || params.Interval <= 0 {
+		return nil, fmt.Errorf("Invalid iceberg display volume (%f) or interval (%s)", params.DisplayVolume, params.Interval)
+	}
+
+	lot_decimals, err := api.lotDecimals(params.Order.Pair)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &ExecutionProgress{Volume: params.Order.Volume}
+	market := newParticipation(api, params.Order.Pair, params.MaxParticipation)
+
+	var txid string
+
+	for progress.Remaining() > 0 {
+		if txid == "" {
+			err := market.update()
+			if err != nil {
+				return progress, err
+			}
+			progress.MarketVolume = market.volume
+
+			remaining := progress.Remaining()
+			if remaining < params.MinVolume || floorVolume(remaining, lot_decimals) == 0 {
+				return progress, unexecutableError(remaining, params.MinVolume)
+			}
+
+			volume := math.Min(params.DisplayVolume, remaining)
+
+			allowed := market.allowed(progress.Executed)
+			if remaining-volume < params.MinVolume && remaining <= allowed {
+				volume = remaining
+			}
+
+			volume = floorVolume(math.Min(volume, allowed), lot_decimals)
+
+			if volume > 0 && volume >= params.MinVolume {
+				txid, err = api.placeChild(&params.Order, volume, progress)
+				if err != nil {
+					return progress, err
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if txid != "" {
+				api.settleChild(txid, progress)
+			}
+			return progress, ctx.Err()
+		case <-time.After(params.Interval):
+		}
+
+		if txid == "" {
+			continue
+		}
+
+		order, err := api.queryOrder(txid)
+		if err != nil {
+			return progress, err
+		}
+
+		if !isFinalStatus(order.Status) {
+			continue
+		}
+
+		progress.Executed += order.VolExec
+		progress.Cost += order.Cost
+
+		if order.Status != "closed" {
+			return progress, fmt.Errorf("Iceberg child order %s was %s", txid, order.Status)
+		}
+
+		txid = ""
+
+		progress.Done = progress.Remaining() == 0
+
+		if params.OnProgress != nil {
+			params.OnProgress(*progress)
+		}
+	}
+
+	return progress, nil
+}
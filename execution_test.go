@@ -0,0 +1,131 @@
+package krakenapi
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Returns a handler of an exchange filling every order at price 100 as soon as it is placed.
+// Volumes of the placed orders are appended to sent, if not nil.
+func instantFillHandler(sent *[]string) http.HandlerFunc {
+	var mutex sync.Mutex
+	orders := make(map[string]float64)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		r.ParseForm()
+
+		switch r.URL.Path {
+		case URL_PUBLIC_ASSET_PAIRS:
+			fmt.Fprint(w, `{"error":[],"result":{"XXBTZEUR":{"altname":"XBTEUR","lot_decimals":8}}}`)
+		case URL_PRIVATE_ADD_ORDER:
+			if sent != nil {
+				*sent = append(*sent, r.Form.Get("volume"))
+			}
+			txid := fmt.Sprintf("O%d", len(orders))
+			orders[txid], _ = strconv.ParseFloat(r.Form.Get("volume"), 64)
+			fmt.Fprintf(w, `{"error":[],"result":{"txid":["%s"]}}`, txid)
+		case URL_PRIVATE_QUERY_ORDERS:
+			txid := r.Form.Get("txid")
+			fmt.Fprintf(w, `{"error":[],"result":{"%s":{"status":"closed","vol":"%v","vol_exec":"%v","cost":"%v"}}}`,
+				txid, orders[txid], orders[txid], orders[txid]*100)
+		}
+	}
+}
+
+func TestIceberg(t *testing.T) {
+	client, server := createTestApiClient(instantFillHandler(nil))
+	defer server.Close()
+
+	progress, err := client.Iceberg(context.Background(), IcebergParams{
+		Order:         OrderRequest{Pair: "XXBTZEUR", Type: "buy", OrderType: "limit", Price: 100, Volume: 1},
+		DisplayVolume: 0.3,
+		Interval:      time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !progress.Done || len(progress.Orders) != 4 || math.Abs(progress.AveragePrice()-100) > 1e-9 {
+		t.Errorf("Unexpected progress: %v", progress)
+	}
+
+	_, err = client.Iceberg(context.Background(), IcebergParams{
+		Order:         OrderRequest{Pair: "XXBTZEUR", Type: "buy", OrderType: "limit", Price: 100, Volume: 1},
+		DisplayVolume: 0.3,
+	})
+	if err == nil {
+		t.Error("A zero interval should be refused")
+	}
+}
+
+func TestTWAP(t *testing.T) {
+	client, server := createTestApiClient(instantFillHandler(nil))
+	defer server.Close()
+
+	updates := 0
+
+	progress, err := client.TWAP(context.Background(), TWAPParams{
+		Order:      OrderRequest{Pair: "XXBTZEUR", Type: "sell", OrderType: "market", Volume: 2},
+		Duration:   10 * time.Millisecond,
+		Slices:     5,
+		Randomness: 0.5,
+		OnProgress: func(ExecutionProgress) { updates++ },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !progress.Done || len(progress.Orders) != 5 || updates != 5 {
+		t.Errorf("Unexpected progress: %v (%d updates)", progress, updates)
+	}
+
+	if math.Abs(progress.Executed-2) > 1e-9 {
+		t.Errorf("Unexpected executed volume: %f", progress.Executed)
+	}
+}
+
+func TestExecutionLotDecimals(t *testing.T) {
+	var sent []string
+
+	client, server := createTestApiClient(instantFillHandler(&sent))
+	defer server.Close()
+
+	progress, err := client.TWAP(context.Background(), TWAPParams{
+		Order:    OrderRequest{Pair: "XXBTZEUR", Type: "sell", OrderType: "market", Volume: 1},
+		Duration: 3 * time.Millisecond,
+		Slices:   3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !progress.Done || fmt.Sprint(sent) != "[0.33333333 0.33333333 0.33333334]" {
+		t.Errorf("Unexpected TWAP children %v: %v", sent, progress)
+	}
+
+	// The last 0.1 is below the minimum volume: it is added to the previous child
+	sent = nil
+
+	progress, err = client.Iceberg(context.Background(), IcebergParams{
+		Order:         OrderRequest{Pair: "XXBTZEUR", Type: "buy", OrderType: "limit", Price: 100, Volume: 1},
+		DisplayVolume: 0.3,
+		MinVolume:     0.2,
+		Interval:      time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !progress.Done || fmt.Sprint(sent) != "[0.3 0.3 0.4]" {
+		t.Errorf("Unexpected iceberg children %v: %v", sent, progress)
+	}
+}
@@ -0,0 +1,154 @@
+package krakenapi
+
+import (
+	"strings"
+)
+
+type FeeEstimate struct {
+	Maker            bool    // estimate for a maker (resting) order, taker otherwise
+	Rate             float64 // fee in percent
+	Fee              float64 // fee amount, in FeeCurrency
+	FeeCurrency      string  // asset the fee is charged in (base or quote asset of the pair)
+	Volume           float64 // 30-day volume used to determine the fee tier
+	NextTierRate     float64 // fee in percent of the next tier, 0 at the lowest fee tier
+	NextTierVolume   float64 // volume level of the next tier, 0 at the lowest fee tier
+	VolumeToNextTier float64 // volume still needed to reach the next tier
+}
+
+// Returns the fee in percent for volume in a [volume, percent fee] schedule,
+// as well as the next tier's volume level and fee (0 if at lowest fee tier).
+func FeeTier(schedule [][]float64, volume float64) (float64, float64, float64) {
+	rate := 0.0
+	next_volume, next_rate := 0.0, 0.0
+
+	for i, tier := range schedule {
+		if len(tier) != 2 || volume < tier[0] {
+			continue
+		}
+
+		rate = tier[1]
+		next_volume, next_rate = 0, 0
+
+		if i+1 < len(schedule) && len(schedule[i+1]) == 2 {
+			next_volume, next_rate = schedule[i+1][0], schedule[i+1][1]
+		}
+	}
+
+	return rate, next_volume, next_rate
+}
+
+/*
+Estimate the fee of an order on pair, for an account with the given 30-day volume.
+
+Maker orders use the pair's maker schedule when it has one. The fee is charged in
+the quote currency when buying and the base currency when selling, unless fciq or
+fcib is set in the order flags. Market orders need their expected price in Price.
+*/
+func EstimateFee(pair AssetPair, volume float64, order *OrderRequest, maker bool) *FeeEstimate {
+	schedule := pair.Fees
+	if maker && len(pair.FeesMaker) > 0 {
+		schedule = pair.FeesMaker
+	}
+
+	estimate := &FeeEstimate{Maker: maker, Volume: volume}
+	estimate.Rate, estimate.NextTierVolume, estimate.NextTierRate = FeeTier(schedule, volume)
+
+	if estimate.NextTierVolume != 0 {
+		estimate.VolumeToNextTier = estimate.NextTierVolume - volume
+	}
+
+	estimate.setFee(pair, order)
+
+	return estimate
+}
+
+// Set the fee amount and currency of an order from the estimate's rate.
+func (estimate *FeeEstimate) setFee(pair AssetPair, order *OrderRequest) {
+	in_base := order.Type == "sell"
+	for _, flag := range strings.Split(order.Oflags, ",") {
+		switch flag {
+		case "fcib":
+			in_base = true
+		case "fciq":
+			in_base = false
+		}
+	}
+
+	if in_base {
+		estimate.FeeCurrency = pair.Base
+		estimate.Fee = order.Volume * estimate.Rate / 100
+	} else {
+		estimate.FeeCurrency = pair.Quote
+		estimate.Fee = order.Price * order.Volume * estimate.Rate / 100
+	}
+}
+
+// FeeCalculator estimates order fees using the account's 30-day volume.
+type FeeCalculator struct {
+	api      *KrakenApi
+	registry *Registry
+}
+
+// Create a fee calculator. The registry provides the pairs' fee schedules.
+func (api *KrakenApi) NewFeeCalculator(registry *Registry) *FeeCalculator {
+	return &FeeCalculator{
+		api:      api,
+		registry: registry,
+	}
+}
+
+/*
+Estimate the fee of an order, using the account's 30-day volume from ApiTradeVolume.
+
+The account's current fee for the pair, as returned by TradeVolume, is used when
+available: it accounts for account specific fee schedules.
+Market orders without a Price are estimated at the ticker's mid price.
+*/
+func (c *FeeCalculator) Estimate(order *OrderRequest, maker bool) (*FeeEstimate, error) {
+	pair_name, err := c.registry.ResolvePair(order.Pair)
+	if err != nil {
+		return nil, err
+	}
+
+	pair, err := c.registry.Pair(pair_name)
+	if err != nil {
+		return nil, err
+	}
+
+	volume, err := c.api.ApiTradeVolume(pair_name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	priced := *order
+	if priced.Price == 0 {
+		tickers, err := c.api.ApiTicker([]string{pair_name})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ticker := range tickers {
+			priced.Price = (ticker.Ask.Price + ticker.Bid.Price) / 2
+		}
+	}
+
+	estimate := EstimateFee(pair, volume.Volume, &priced, maker)
+
+	fees := volume.Fees
+	if maker && len(volume.FeesMaker) > 0 {
+		fees = volume.FeesMaker
+	}
+
+	if fee, ok := fees[pair_name]; ok {
+		estimate.Rate = fee.Fee
+		estimate.setFee(pair, &priced)
+		estimate.NextTierRate = fee.Nextfee
+		estimate.NextTierVolume = fee.Nextvolume
+		estimate.VolumeToNextTier = 0
+		if fee.Nextvolume != 0 {
+			estimate.VolumeToNextTier = fee.Nextvolume - volume.Volume
+		}
+	}
+
+	return estimate, nil
+}
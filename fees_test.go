@@ -0,0 +1,64 @@
+package krakenapi
+
+import (
+	"math"
+	"net/http"
+	"testing"
+)
+
+var testFeeSchedule = [][]float64{{0, 0.26}, {50000, 0.24}, {100000, 0.22}}
+var testMakerFeeSchedule = [][]float64{{0, 0.16}, {50000, 0.14}, {100000, 0.12}}
+
+func TestFeeTier(t *testing.T) {
+	tests := []struct {
+		volume, rate, next_volume, next_rate float64
+	}{
+		{0, 0.26, 50000, 0.24},
+		{60000, 0.24, 100000, 0.22},
+		{200000, 0.22, 0, 0},
+	}
+
+	for _, test := range tests {
+		rate, next_volume, next_rate := FeeTier(testFeeSchedule, test.volume)
+		if rate != test.rate || next_volume != test.next_volume || next_rate != test.next_rate {
+			t.Errorf("%f: unexpected tier %f %f %f", test.volume, rate, next_volume, next_rate)
+		}
+	}
+}
+
+func TestEstimateFee(t *testing.T) {
+	pair := AssetPair{Base: "XXBT", Quote: "ZEUR", Fees: testFeeSchedule, FeesMaker: testMakerFeeSchedule}
+
+	estimate := EstimateFee(pair, 10000, &OrderRequest{Type: "buy", Price: 100, Volume: 2}, false)
+	if estimate.FeeCurrency != "ZEUR" || math.Abs(estimate.Fee-0.52) > 1e-9 || estimate.VolumeToNextTier != 40000 {
+		t.Errorf("Unexpected taker estimate %v", estimate)
+	}
+
+	estimate = EstimateFee(pair, 10000, &OrderRequest{Type: "buy", Price: 100, Volume: 2, Oflags: "post,fcib"}, true)
+	if estimate.FeeCurrency != "XXBT" || math.Abs(estimate.Fee-0.0032) > 1e-9 {
+		t.Errorf("Unexpected maker estimate %v", estimate)
+	}
+}
+
+func TestFeeCalculator(t *testing.T) {
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error":[],"result":{"currency":"ZUSD","volume":"60000.0000","fees":{"XXBTZEUR":{"fee":"0.2000","minfee":"0.1000","maxfee":"0.2600","nextfee":null,"nextvolume":null,"tiervolume":"50000.0000"}}}}`))
+	})
+	defer server.Close()
+
+	registry := createTestRegistry()
+	pairs := registry.pairs
+	pair := pairs["XXBTZEUR"]
+	pair.Fees = testFeeSchedule
+	pairs["XXBTZEUR"] = pair
+	registry.load(registry.assets, pairs)
+
+	estimate, err := client.NewFeeCalculator(registry).Estimate(&OrderRequest{Pair: "BTC/EUR", Type: "sell", Price: 100, Volume: 1}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if estimate.Rate != 0.2 || estimate.FeeCurrency != "XXBT" || math.Abs(estimate.Fee-0.002) > 1e-9 || estimate.NextTierVolume != 0 {
+		t.Errorf("Unexpected estimate %v", estimate)
+	}
+}
@@ -1,6 +1,7 @@
 package krakenapi
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -10,6 +11,11 @@ import (
 )
 
 func executeHttpQuery(method string, url string, headers map[string]string, values url.Values) ([]byte, error) {
+	return executeHttpQueryContext(context.Background(), method, url, headers, values)
+}
+
+// Same as executeHttpQuery, the request being aborted once ctx is done.
+func executeHttpQueryContext(ctx context.Context, method string, url string, headers map[string]string, values url.Values) ([]byte, error) {
 	var bodyReader io.Reader
 
 	client := &http.Client{}
@@ -21,7 +27,7 @@ func executeHttpQuery(method string, url string, headers map[string]string, valu
 		bodyReader = strings.NewReader(values.Encode())
 	}
 
-	req, err := http.NewRequest(method, url, bodyReader)
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 	if err != nil {
 		return nil, fmt.Errorf("Could not execute request! (%s)", err.Error())
 	}
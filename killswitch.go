@@ -0,0 +1,248 @@
+package krakenapi
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Private endpoints still allowed once a kill switch tripped, besides read endpoints.
+var cancelEndpoints = map[string]bool{
+	URL_PRIVATE_CANCEL_ORDER:       true,
+	URL_PRIVATE_CANCEL_ORDER_BATCH: true,
+	URL_PRIVATE_CANCEL_ALL:         true,
+	URL_PRIVATE_CANCEL_ALL_AFTER:   true,
+}
+
+// Returned by Query for order endpoints once the kill switch tripped.
+type KillSwitchError struct {
+	Reason string
+	Path   string
+}
+
+func (e *KillSwitchError) Error() string {
+	return fmt.Sprintf("%s refused: kill switch tripped (%s)", e.Path, e.Reason)
+}
+
+/*
+KillSwitch cancels all open orders when tripped, optionally settles open margin
+positions, and makes all later order calls fail until re-armed.
+
+The same kill switch can be shared by several clients by setting their
+KillSwitch field: all of them are blocked when it trips.
+It can be tripped by a call to Trip, a signal, a file or a drawdown rule.
+*/
+type KillSwitch struct {
+	api              *KrakenApi
+	FlattenPositions bool                           // settle open margin positions when tripped
+	OnTrip           func(reason string, err error) // called when tripped, with the error met while cancelling (optional)
+
+	mutex   sync.Mutex
+	tripped bool
+	reason  string
+	stop    chan struct{}
+}
+
+// Create a kill switch using this client to cancel orders, and attach it to this client.
+func (api *KrakenApi) NewKillSwitch(flatten bool) *KillSwitch {
+	ks := &KillSwitch{
+		api:              api,
+		FlattenPositions: flatten,
+		stop:             make(chan struct{}),
+	}
+
+	api.KillSwitch = ks
+
+	return ks
+}
+
+// Returns whether the kill switch tripped, and why.
+func (ks *KillSwitch) Tripped() (bool, string) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	return ks.tripped, ks.reason
+}
+
+// Returns whether url_path is refused by the kill switch.
+func (ks *KillSwitch) refuses(url_path string) (bool, string) {
+	tripped, reason := ks.Tripped()
+	if !tripped {
+		return false, ""
+	}
+
+	if isPublicEndpoint(url_path) || readEndpoints[url_path] || safeEndpoints[url_path] || cancelEndpoints[url_path] {
+		return false, ""
+	}
+
+	return true, reason
+}
+
+/*
+Trip the kill switch: block all order calls, cancel all open orders and, if
+FlattenPositions is set, settle open margin positions.
+Tripping an already tripped kill switch cancels orders again.
+*/
+func (ks *KillSwitch) Trip(reason string) error {
+	ks.mutex.Lock()
+	ks.tripped = true
+	ks.reason = reason
+	ks.mutex.Unlock()
+
+	_, err := ks.api.ApiCancelAll()
+
+	if err == nil && ks.FlattenPositions {
+		err = ks.flatten()
+	}
+
+	if ks.OnTrip != nil {
+		ks.OnTrip(reason, err)
+	}
+
+	return err
+}
+
+// Allow order calls again.
+func (ks *KillSwitch) Rearm() {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	ks.tripped = false
+	ks.reason = ""
+}
+
+// Settle all open margin positions with settle-position orders.
+func (ks *KillSwitch) flatten() error {
+	positions, err := ks.api.ApiOpenPositions("", false)
+	if err != nil {
+		return err
+	}
+
+	// Settle orders are sent by a copy of the client, not blocked by the kill switch.
+	client := *ks.api
+	client.KillSwitch = nil
+	client.PreTradeCheck = nil
+	client.PreTradeBatchCheck = nil
+
+	settled := make(map[string]bool)
+
+	for _, position := range positions {
+		key := position.Pair + "/" + position.Type
+		if settled[key] {
+			continue
+		}
+		settled[key] = true
+
+		order := &OrderRequest{
+			Pair:      position.Pair,
+			Type:      "sell",
+			OrderType: "settle-position",
+			Volume:    0, // settles the whole position
+		}
+		if position.Type == "sell" {
+			order.Type = "buy"
+		}
+
+		if position.Margin != 0 {
+			order.Leverage = strconv.Itoa(int(math.Round(position.Cost / position.Margin)))
+		}
+
+		_, err = client.ApiAddOrderRequest(order)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Trip the kill switch when one of the given signals is received.
+func (ks *KillSwitch) TripOnSignal(signals ...os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+
+	go func() {
+		defer signal.Stop(ch)
+
+		select {
+		case sig := <-ch:
+			ks.Trip(fmt.Sprintf("received signal %s", sig))
+		case <-ks.stop:
+		}
+	}()
+}
+
+// Trip the kill switch when the file at path exists, checking every interval.
+func (ks *KillSwitch) TripOnFile(path string, interval time.Duration) error {
+	return ks.watch(interval, func() (bool, string) {
+		_, err := os.Stat(path)
+		return err == nil, fmt.Sprintf("file %s exists", path)
+	})
+}
+
+/*
+Trip the kill switch when equity (see ApiTradeBalance) falls by more than
+max_drawdown (ie: 0.1 for 10%) from its highest value since the call, checking
+every interval. Equity is expressed in asset (ie: ZEUR).
+*/
+func (ks *KillSwitch) TripOnDrawdown(asset string, max_drawdown float64, interval time.Duration) error {
+	peak := 0.0
+
+	return ks.watch(interval, func() (bool, string) {
+		balance, err := ks.api.ApiTradeBalance(asset)
+		if err != nil {
+			return false, ""
+		}
+
+		peak = math.Max(peak, balance.E)
+		drawdown := (peak - balance.E) / peak
+
+		return peak > 0 && drawdown > max_drawdown, fmt.Sprintf("drawdown %.2f%% from %f %s", drawdown*100, peak, asset)
+	})
+}
+
+// Trip the kill switch once check returns true, checking every interval.
+func (ks *KillSwitch) watch(interval time.Duration, check func() (bool, string)) error {
+	if interval <= 0 {
+		return fmt.Errorf("Invalid kill switch check interval %s", interval)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ks.stop:
+				return
+			case <-ticker.C:
+				if tripped, _ := ks.Tripped(); tripped {
+					continue
+				}
+
+				trip, reason := check()
+				if trip {
+					ks.Trip(reason)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop all signal, file and drawdown watchers. The kill switch state is left untouched.
+func (ks *KillSwitch) Close() {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	select {
+	case <-ks.stop:
+	default:
+		close(ks.stop)
+	}
+}
@@ -0,0 +1,103 @@
+package krakenapi
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKillSwitch(t *testing.T) {
+	var mutex sync.Mutex
+	queried := []string{}
+
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		r.ParseForm()
+		queried = append(queried, r.URL.Path+" "+r.Form.Get("ordertype"))
+
+		switch r.URL.Path {
+		case URL_PRIVATE_OPEN_POSITIONS:
+			w.Write([]byte(`{"error":[],"result":{"T1":{"pair":"XXBTZEUR","type":"buy","cost":"1000","margin":"200","vol":"1","vol_closed":"0"},"T2":{"pair":"XXBTZEUR","type":"buy","cost":"500","margin":"100","vol":"1","vol_closed":"0"}}}`))
+		case URL_PRIVATE_ADD_ORDER:
+			w.Write([]byte(`{"error":[],"result":{"txid":["OTXID"]}}`))
+		default:
+			w.Write([]byte(`{"error":[],"result":{"count":1}}`))
+		}
+	})
+	defer server.Close()
+
+	ks := client.NewKillSwitch(true)
+	defer ks.Close()
+
+	err := ks.Trip("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{URL_PRIVATE_CANCEL_ALL + " ", URL_PRIVATE_OPEN_POSITIONS + " ", URL_PRIVATE_ADD_ORDER + " settle-position"}
+	if len(queried) != len(expected) {
+		t.Fatalf("Unexpected queries: %v", queried)
+	}
+	for i := range expected {
+		if queried[i] != expected[i] {
+			t.Errorf("Unexpected query %s instead of %s", queried[i], expected[i])
+		}
+	}
+
+	_, err = client.ApiAddOrder("XXBTZEUR", "buy", "limit", 1, 0, 1, "")
+	if _, ok := err.(*KillSwitchError); !ok {
+		t.Errorf("AddOrder should be refused, got %v", err)
+	}
+
+	_, err = client.ApiCancelOrder("OTXID")
+	if err != nil {
+		t.Errorf("CancelOrder should be allowed, got %v", err)
+	}
+
+	ks.Rearm()
+
+	_, err = client.ApiAddOrder("XXBTZEUR", "buy", "limit", 1, 0, 1, "")
+	if err != nil {
+		t.Errorf("AddOrder should be allowed once re-armed, got %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "killswitch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "STOP")
+	ks.FlattenPositions = false
+	err = ks.TripOnFile(path, 0)
+	if err == nil {
+		t.Error("A zero interval should be refused")
+	}
+
+	err = ks.TripOnDrawdown("ZEUR", 0.1, -time.Second)
+	if err == nil {
+		t.Error("A negative interval should be refused")
+	}
+
+	err = ks.TripOnFile(path, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ioutil.WriteFile(path, []byte{}, 0600)
+
+	for i := 0; i < 100; i++ {
+		if tripped, _ := ks.Tripped(); tripped {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Errorf("Kill switch did not trip on file")
+}
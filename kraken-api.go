@@ -2,11 +2,13 @@
 package krakenapi
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
@@ -22,27 +24,46 @@ const (
 	URL_PUBLIC_RECENT_TRADES = "/0/public/Trades"
 	URL_PUBLIC_SPREAD        = "/0/public/Spread"
 
-	URL_PRIVATE_BALANCE        = "/0/private/Balance"
-	URL_PRIVATE_TRADE_BALANCE  = "/0/private/TradeBalance"
-	URL_PRIVATE_OPEN_ORDERS    = "/0/private/OpenOrders"
-	URL_PRIVATE_CLOSED_ORDERS  = "/0/private/ClosedOrders"
-	URL_PRIVATE_QUERY_ORDERS   = "/0/private/QueryOrders"
-	URL_PRIVATE_TRADES_HISTORY = "/0/private/TradesHistory"
-	URL_PRIVATE_QUERY_TRADES   = "/0/private/QueryTrades"
-	URL_PRIVATE_OPEN_POSITIONS = "/0/private/OpenPositions"
-	URL_PRIVATE_LEDGERS        = "/0/private/Ledgers"
-	URL_PRIVATE_QUERY_LEDGERS  = "/0/private/QueryLedgers"
-	URL_PRIVATE_TRADE_VOLUME   = "/0/private/TradeVolume"
-	URL_PRIVATE_ADD_ORDER      = "/0/private/AddOrder"
-	URL_PRIVATE_CANCEL_ORDER   = "/0/private/CancelOrder"
+	URL_PRIVATE_BALANCE            = "/0/private/Balance"
+	URL_PRIVATE_BALANCE_EX         = "/0/private/BalanceEx"
+	URL_PRIVATE_TRADE_BALANCE      = "/0/private/TradeBalance"
+	URL_PRIVATE_OPEN_ORDERS        = "/0/private/OpenOrders"
+	URL_PRIVATE_CLOSED_ORDERS      = "/0/private/ClosedOrders"
+	URL_PRIVATE_QUERY_ORDERS       = "/0/private/QueryOrders"
+	URL_PRIVATE_TRADES_HISTORY     = "/0/private/TradesHistory"
+	URL_PRIVATE_QUERY_TRADES       = "/0/private/QueryTrades"
+	URL_PRIVATE_OPEN_POSITIONS     = "/0/private/OpenPositions"
+	URL_PRIVATE_LEDGERS            = "/0/private/Ledgers"
+	URL_PRIVATE_QUERY_LEDGERS      = "/0/private/QueryLedgers"
+	URL_PRIVATE_TRADE_VOLUME       = "/0/private/TradeVolume"
+	URL_PRIVATE_ADD_ORDER          = "/0/private/AddOrder"
+	URL_PRIVATE_CANCEL_ORDER       = "/0/private/CancelOrder"
+	URL_PRIVATE_EDIT_ORDER         = "/0/private/EditOrder"
+	URL_PRIVATE_AMEND_ORDER        = "/0/private/AmendOrder"
+	URL_PRIVATE_ADD_ORDER_BATCH    = "/0/private/AddOrderBatch"
+	URL_PRIVATE_CANCEL_ORDER_BATCH = "/0/private/CancelOrderBatch"
+	URL_PRIVATE_CANCEL_ALL         = "/0/private/CancelAll"
+	URL_PRIVATE_CANCEL_ALL_AFTER   = "/0/private/CancelAllOrdersAfter"
+	URL_PRIVATE_WEBSOCKETS_TOKEN   = "/0/private/GetWebSocketsToken"
 )
 
 type KrakenApi struct {
-	Key       string
-	secret    string
-	ApiRoot   string
-	UserAgent string
-	Client    *http.Client
+	Key         string
+	secret      string
+	ApiRoot     string
+	UserAgent   string
+	Client      *http.Client
+	Mode        ClientMode   // endpoints allowed, MODE_FULL by default
+	KillSwitch  *KillSwitch  // once tripped, order endpoints are refused (optional)
+	RateLimiter *RateLimiter // Query waits for it before each call (optional)
+
+	// Called before any order is sent by AddOrder or AddOrderBatch. Returning
+	// an error prevents the order from being sent (see RiskChecker).
+	PreTradeCheck func(order *OrderRequest) error
+
+	// Called instead of PreTradeCheck with all the orders of an AddOrderBatch, so
+	// that limits apply to the batch as a whole (see RiskChecker.CheckBatch).
+	PreTradeBatchCheck func(orders []*OrderRequest) error
 }
 
 // Create a new KrakenApi client
@@ -51,7 +72,45 @@ func New(key string, secret string) *KrakenApi {
 	client := &http.Client{}
 	user_agent := "kraken-api"
 
-	return &KrakenApi{key, secret, URL_ROOT, user_agent, client}
+	return &KrakenApi{
+		Key:       key,
+		secret:    secret,
+		ApiRoot:   URL_ROOT,
+		UserAgent: user_agent,
+		Client:    client,
+	}
+}
+
+// Error returned when Kraken answered the request with errors.
+// Other errors (network, decoding...) leave the outcome of the request unknown.
+type ApiError struct {
+	Errors []string
+}
+
+func (e *ApiError) Error() string {
+	return fmt.Sprintf("Could not execute request! (%s)", e.Errors)
+}
+
+// Returns whether the request was refused because of Kraken's rate limits.
+func (e *ApiError) RateLimited() bool {
+	for _, err := range e.Errors {
+		if strings.HasPrefix(err, "EAPI:Rate limit exceeded") || strings.HasPrefix(err, "EGeneral:Too many requests") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns whether Kraken failed on its side, the request possibly having been processed anyway.
+func (e *ApiError) Unavailable() bool {
+	for _, err := range e.Errors {
+		if strings.HasPrefix(err, "EService:Unavailable") || strings.HasPrefix(err, "EService:Busy") || strings.HasPrefix(err, "EGeneral:Internal error") {
+			return true
+		}
+	}
+
+	return false
 }
 
 func parse(resp []byte, struct_type interface{}) (interface{}, error) {
@@ -67,13 +126,45 @@ func parse(resp []byte, struct_type interface{}) (interface{}, error) {
 	}
 
 	if len(response.Error) > 0 {
-		return nil, fmt.Errorf("Could not execute request! (%s)", response.Error)
+		return nil, &ApiError{response.Error}
 	}
 
 	return response.Result, nil
 }
 
+// Returns an error if the client mode or the kill switch refuses url_path.
+func (api *KrakenApi) checkEndpoint(url_path string) error {
+	if !api.Mode.allows(url_path) {
+		return &ModeError{api.Mode, url_path}
+	}
+
+	if api.KillSwitch != nil {
+		if refused, reason := api.KillSwitch.refuses(url_path); refused {
+			return &KillSwitchError{reason, url_path}
+		}
+	}
+
+	return nil
+}
+
 func (api *KrakenApi) Query(url_path string, params url.Values, with_signature bool) ([]byte, error) {
+	return api.queryContext(context.Background(), url_path, params, with_signature)
+}
+
+// Same as Query, giving up waiting for the rate limiter or for the response once ctx is done.
+func (api *KrakenApi) queryContext(ctx context.Context, url_path string, params url.Values, with_signature bool) ([]byte, error) {
+	err := api.checkEndpoint(url_path)
+	if err != nil {
+		return nil, err
+	}
+
+	if api.RateLimiter != nil {
+		err = api.RateLimiter.Wait(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	headers := map[string]string{}
 	method := "GET"
 
@@ -95,5 +186,5 @@ func (api *KrakenApi) Query(url_path string, params url.Values, with_signature b
 
 	headers["Content-Type"] = "application/x-www-form-urlencoded"
 
-	return executeHttpQuery(method, api.ApiRoot+url_path, headers, params)
+	return executeHttpQueryContext(ctx, method, api.ApiRoot+url_path, headers, params)
 }
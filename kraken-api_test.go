@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"log"
 	"testing"
+	"time"
 )
 
 var api = CreatePrivateApiClient()
@@ -106,15 +107,18 @@ func TestApiTicker(t *testing.T) {
 func TestApiOHLC(t *testing.T) {
 	log.Println("TestApiOHLC...")
 
-	last, data, err := api.ApiOHLC("XXBTZEUR", 0, 0)
+	data, err := api.ApiOHLC("XXBTZEUR", OHLC_1H, 0)
 	if err != nil {
 		panic(err)
 	}
 
-	log.Printf("last: %f\n", last)
-	for unit := range data {
-		log.Println(data[unit])
+	log.Printf("last: %d\n", data.Last)
+	for _, entry := range data.Committed("XXBTZEUR") {
+		log.Println(entry)
 	}
+
+	current, _ := data.Current("XXBTZEUR")
+	log.Println("current:", current)
 }
 
 func TestApiDepth(t *testing.T) {
@@ -288,6 +292,108 @@ func TestApiAddOrder(t *testing.T) {
 	}
 }
 
+func TestApiEditOrder(t *testing.T) {
+	log.Println("TestApiEditOrder...")
+
+	order, err := api.ApiAddOrderRequest(&OrderRequest{
+		Pair:      "XXBTZEUR",
+		Type:      "buy",
+		OrderType: "limit",
+		Price:     1,
+		Volume:    0.1,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	edited, err := api.ApiEditOrder(order.Txid[0], &OrderRequest{Pair: "XXBTZEUR", Price: 2})
+	if err != nil {
+		panic(err)
+	}
+
+	log.Printf("%s -> %s\n", edited.OriginalTxid, edited.Txid)
+
+	amended, err := api.ApiAmendOrder(edited.Txid, &OrderRequest{Price: 3})
+	if err != nil {
+		panic(err)
+	}
+
+	log.Println(amended)
+
+	cancel_result, err := api.ApiCancelOrder(amended.Txid)
+	if err != nil {
+		panic(err)
+	}
+	log.Println(cancel_result)
+}
+
+func TestApiAddOrderBatch(t *testing.T) {
+	log.Println("TestApiAddOrderBatch...")
+
+	orders, err := api.ApiAddOrderBatch("XXBTZEUR", []*OrderRequest{
+		{Type: "buy", OrderType: "limit", Price: 1, Volume: 0.1},
+		{Type: "buy", OrderType: "limit", Price: 2, Volume: 0.1},
+	}, false)
+	if err != nil {
+		panic(err)
+	}
+
+	txids := []string{}
+	for _, order := range orders {
+		if order.Err != nil {
+			panic(order.Err)
+		}
+
+		log.Println(order.Result)
+		txids = append(txids, order.Result.Txid...)
+	}
+
+	log.Println("TestApiCancelOrderBatch...")
+
+	cancel_result, err := api.ApiCancelOrderBatch(txids)
+	if err != nil {
+		panic(err)
+	}
+	log.Println(cancel_result)
+}
+
+func TestApiCancelAll(t *testing.T) {
+	log.Println("TestApiCancelAll...")
+
+	// Only cancel our own order, the account may have others open
+	result, err := api.ApiAddOrder("XXBTZEUR", "buy", "limit", 1, 0, 0.1, "")
+	if err != nil {
+		panic(err)
+	}
+
+	cancel_result, err := api.ApiCancelOrder(result.Txid[0])
+	if err != nil {
+		panic(err)
+	}
+	log.Println(cancel_result)
+}
+
+func TestDeadManSwitch(t *testing.T) {
+	log.Println("TestDeadManSwitch...")
+
+	dms := api.NewDeadManSwitch(60*time.Second, 2*time.Second)
+	dms.OnArm = func(result *CancelAllAfterResult) {
+		log.Printf("armed at %s, trigger at %s\n", result.CurrentTime, result.TriggerTime)
+	}
+
+	err := dms.Start()
+	if err != nil {
+		panic(err)
+	}
+
+	time.Sleep(5 * time.Second)
+
+	err = dms.Stop()
+	if err != nil {
+		panic(err)
+	}
+}
+
 func DumpOrder(order_id string, order *Order) {
 	log.Printf("%s: refid%s userref%s status:%s descr:%s opentm:%f closetm:%f\n",
 		order_id,
@@ -0,0 +1,78 @@
+package krakenapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Restricts which endpoints a client may call. Enforced by Query.
+type ClientMode int
+
+const (
+	MODE_FULL           ClientMode = iota // all endpoints
+	MODE_TRADE_DISABLED                   // no order entry, no cancels, no withdrawals
+	MODE_READ_ONLY                        // public endpoints and private read endpoints only
+)
+
+func (m ClientMode) String() string {
+	switch m {
+	case MODE_FULL:
+		return "full"
+	case MODE_TRADE_DISABLED:
+		return "trade disabled"
+	case MODE_READ_ONLY:
+		return "read only"
+	}
+
+	return fmt.Sprintf("ClientMode(%d)", int(m))
+}
+
+// Private endpoints which never change anything on the account.
+var readEndpoints = map[string]bool{
+	URL_PRIVATE_BALANCE:        true,
+	URL_PRIVATE_BALANCE_EX:     true,
+	URL_PRIVATE_TRADE_BALANCE:  true,
+	URL_PRIVATE_OPEN_ORDERS:    true,
+	URL_PRIVATE_CLOSED_ORDERS:  true,
+	URL_PRIVATE_QUERY_ORDERS:   true,
+	URL_PRIVATE_TRADES_HISTORY: true,
+	URL_PRIVATE_QUERY_TRADES:   true,
+	URL_PRIVATE_OPEN_POSITIONS: true,
+	URL_PRIVATE_LEDGERS:        true,
+	URL_PRIVATE_QUERY_LEDGERS:  true,
+	URL_PRIVATE_TRADE_VOLUME:   true,
+}
+
+// Private endpoints which neither trade nor move funds.
+var safeEndpoints = map[string]bool{
+	URL_PRIVATE_WEBSOCKETS_TOKEN: true,
+}
+
+// Returned by Query when the client mode does not allow calling an endpoint.
+type ModeError struct {
+	Mode ClientMode
+	Path string
+}
+
+func (e *ModeError) Error() string {
+	return fmt.Sprintf("%s is not allowed in %s mode", e.Path, e.Mode)
+}
+
+func isPublicEndpoint(url_path string) bool {
+	return strings.HasPrefix(url_path, "/0/public/")
+}
+
+// Returns whether mode allows calling url_path.
+// Unknown private endpoints are refused by restricted modes, so that new
+// endpoints can not bypass the mode.
+func (m ClientMode) allows(url_path string) bool {
+	if m == MODE_FULL || isPublicEndpoint(url_path) {
+		return true
+	}
+
+	if readEndpoints[url_path] {
+		return true
+	}
+
+	return m == MODE_TRADE_DISABLED && safeEndpoints[url_path]
+}
@@ -0,0 +1,44 @@
+package krakenapi
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestClientModes(t *testing.T) {
+	queried := []string{}
+
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		queried = append(queried, r.URL.Path)
+		w.Write([]byte(`{"error":[],"result":{}}`))
+	})
+	defer server.Close()
+
+	for _, mode := range []ClientMode{MODE_READ_ONLY, MODE_TRADE_DISABLED} {
+		client.Mode = mode
+
+		_, err := client.ApiBalance()
+		if err != nil {
+			t.Errorf("%s: %s", mode, err)
+		}
+
+		_, err = client.ApiAddOrder("XXBTZEUR", "buy", "limit", 1, 0, 1, "")
+		if _, ok := err.(*ModeError); !ok {
+			t.Errorf("%s: AddOrder should be refused, got %v", mode, err)
+		}
+
+		_, err = client.ApiCancelOrder("OTXID")
+		if _, ok := err.(*ModeError); !ok {
+			t.Errorf("%s: CancelOrder should be refused, got %v", mode, err)
+		}
+
+		_, err = client.Query("/0/private/Withdraw", nil, true)
+		if _, ok := err.(*ModeError); !ok {
+			t.Errorf("%s: Withdraw should be refused, got %v", mode, err)
+		}
+	}
+
+	if len(queried) != 2 || queried[0] != URL_PRIVATE_BALANCE {
+		t.Errorf("Unexpected queries: %v", queried)
+	}
+}
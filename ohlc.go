@@ -0,0 +1,96 @@
+package krakenapi
+
+import (
+	"context"
+	"time"
+)
+
+/*
+OHLCIterator polls OHLC data of a pair and yields each committed candle once.
+
+The current, not-yet-committed candle is never yielded by Next, but its latest
+known state is available from Current.
+*/
+type OHLCIterator struct {
+	api          *KrakenApi
+	Pair         string
+	Interval     OHLCInterval
+	PollInterval time.Duration // delay between two polls when no new candle is committed
+
+	since    int64
+	lastTime float64
+	current  OHLCEntry
+}
+
+/*
+Create an OHLC iterator yielding the candles committed after since (0 for all
+candles returned by Kraken, ie: the last 720 candles).
+Polls every quarter of the interval, at most every minute.
+*/
+func (api *KrakenApi) NewOHLCIterator(pair string, interval OHLCInterval, since int64) *OHLCIterator {
+	if interval == 0 {
+		interval = OHLC_1M
+	}
+
+	poll := interval.Duration() / 4
+	if poll > time.Minute {
+		poll = time.Minute
+	}
+
+	return &OHLCIterator{
+		api:          api,
+		Pair:         pair,
+		Interval:     interval,
+		PollInterval: poll,
+		since:        since,
+		lastTime:     float64(since),
+	}
+}
+
+/*
+Returns the candles committed since the previous call, oldest first, polling
+until there is at least one. Returns the context's error if it is done first.
+*/
+func (it *OHLCIterator) Next(ctx context.Context) ([]OHLCEntry, error) {
+	for {
+		result, err := it.api.ApiOHLC(it.Pair, it.Interval, it.since)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Last != 0 {
+			it.since = result.Last
+		}
+
+		committed := []OHLCEntry{}
+
+		for pair := range result.Pairs {
+			if current, ok := result.Current(pair); ok {
+				it.current = current
+			}
+
+			// Pages may overlap, only yield candles newer than the previous ones.
+			for _, entry := range result.Committed(pair) {
+				if entry.Time > it.lastTime {
+					committed = append(committed, entry)
+				}
+			}
+		}
+
+		if len(committed) > 0 {
+			it.lastTime = committed[len(committed)-1].Time
+			return committed, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(it.PollInterval):
+		}
+	}
+}
+
+// Returns the latest known state of the current, not-yet-committed candle.
+func (it *OHLCIterator) Current() OHLCEntry {
+	return it.current
+}
@@ -0,0 +1,101 @@
+package krakenapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestApiOHLCResult(t *testing.T) {
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("interval") != "60" {
+			t.Errorf("Unexpected interval %s", r.FormValue("interval"))
+		}
+
+		w.Write([]byte(`{"error":[],"result":{"XXBTZEUR":[
+			[1700000000,"100.0","110.0","90.0","105.0","101.5","2.5",12],
+			[1700003600,"105.0","106.0","104.0","105.5","105.2","0.5",3]
+		],"last":1700000000}}`))
+	})
+	defer server.Close()
+
+	result, err := client.ApiOHLC("XXBTZEUR", OHLC_1H, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Last != 1700000000 {
+		t.Errorf("Unexpected last %d", result.Last)
+	}
+
+	committed := result.Committed("XXBTZEUR")
+	if len(committed) != 1 || committed[0].Close != 105 || committed[0].Count != 12 || !committed[0].Committed {
+		t.Errorf("Unexpected committed candles %v", committed)
+	}
+
+	current, ok := result.Current("XXBTZEUR")
+	if !ok || current.Committed || current.Time != 1700003600 || current.Volume != 0.5 {
+		t.Errorf("Unexpected current candle %v", current)
+	}
+
+	_, err = client.ApiOHLC("XXBTZEUR", 2, 0)
+	if err == nil {
+		t.Error("Invalid interval should be refused")
+	}
+}
+
+func TestOHLCIterator(t *testing.T) {
+	responses := []string{
+		`{"error":[],"result":{"XXBTZEUR":[[60,"1","1","1","1","1","1",1],[120,"2","2","2","2","2","2",2],[180,"3","3","3","3","3","3",3]],"last":120}}`,
+		`{"error":[],"result":{"XXBTZEUR":[[120,"2","2","2","2","2","2",2],[180,"3","3","3","3","3","3",4]],"last":120}}`,
+		`{"error":[],"result":{"XXBTZEUR":[[120,"2","2","2","2","2","2",2],[180,"3","3","3","3","3","3",5],[240,"4","4","4","4","4","4",1]],"last":180}}`,
+	}
+	sinces := []string{}
+
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		sinces = append(sinces, r.FormValue("since"))
+		w.Write([]byte(responses[0]))
+		if len(responses) > 1 {
+			responses = responses[1:]
+		}
+	})
+	defer server.Close()
+
+	it := client.NewOHLCIterator("XXBTZEUR", OHLC_1M, 0)
+	it.PollInterval = time.Millisecond
+
+	entries, err := it.Next(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 || entries[0].Time != 60 || entries[1].Time != 120 {
+		t.Errorf("Unexpected first candles %v", entries)
+	}
+
+	entries, err = it.Next(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Time != 180 || entries[0].Count != 5 {
+		t.Errorf("Unexpected new candles %v", entries)
+	}
+
+	if it.Current().Time != 240 {
+		t.Errorf("Unexpected current candle %v", it.Current())
+	}
+
+	if len(sinces) != 3 || sinces[0] != "" || sinces[1] != "120" || sinces[2] != "120" {
+		t.Errorf("Unexpected since parameters %v", sinces)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = it.Next(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+}
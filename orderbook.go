@@ -0,0 +1,218 @@
+package krakenapi
+
+import (
+	"sort"
+	"sync"
+)
+
+/*
+OrderBook is a local order book with sorted price levels, loaded from a Depth
+snapshot and kept current by applying updates.
+
+Any number of goroutines can read the book while one goroutine applies updates.
+*/
+type OrderBook struct {
+	Pair  string
+	Depth int // maximum number of levels kept per side, 0 for no cap
+
+	mutex sync.RWMutex
+	asks  []PublicOrder // lowest price first
+	bids  []PublicOrder // highest price first
+}
+
+func NewOrderBook(pair string, depth int) *OrderBook {
+	return &OrderBook{
+		Pair:  pair,
+		Depth: depth,
+	}
+}
+
+// Create an order book for pair, loaded from a Depth snapshot of depth levels (0 for Kraken's default).
+func (api *KrakenApi) LoadOrderBook(pair string, depth int) (*OrderBook, error) {
+	books, err := api.ApiDepth(pair, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	book := NewOrderBook(pair, depth)
+
+	for _, snapshot := range books {
+		book.Load(snapshot)
+	}
+
+	return book, nil
+}
+
+// Returns whether price a comes before price b on a side.
+func sideBefore(ask bool, a, b float64) bool {
+	if ask {
+		return a < b
+	}
+
+	return a > b
+}
+
+func sortedSide(orders []PublicOrder, ask bool, depth int) []PublicOrder {
+	side := make([]PublicOrder, 0, len(orders))
+	for _, order := range orders {
+		if order.Volume > 0 {
+			side = append(side, order)
+		}
+	}
+
+	sort.Slice(side, func(i, j int) bool { return sideBefore(ask, side[i].Price, side[j].Price) })
+
+	if depth > 0 && len(side) > depth {
+		side = side[:depth]
+	}
+
+	return side
+}
+
+// Replace the whole book with a snapshot.
+func (b *OrderBook) Load(snapshot PublicOrderBook) {
+	asks := sortedSide(snapshot.Asks, true, b.Depth)
+	bids := sortedSide(snapshot.Bids, false, b.Depth)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.asks = asks
+	b.bids = bids
+}
+
+// Set a level on a side: a zero volume removes the level.
+func updateSide(side []PublicOrder, update PublicOrder, ask bool, depth int) []PublicOrder {
+	i := sort.Search(len(side), func(i int) bool { return !sideBefore(ask, side[i].Price, update.Price) })
+	exists := i < len(side) && side[i].Price == update.Price
+
+	switch {
+	case update.Volume <= 0 && exists:
+		side = append(side[:i], side[i+1:]...)
+	case update.Volume <= 0:
+	case exists:
+		side[i] = update
+	default:
+		side = append(side, PublicOrder{})
+		copy(side[i+1:], side[i:])
+		side[i] = update
+	}
+
+	if depth > 0 && len(side) > depth {
+		side = side[:depth]
+	}
+
+	return side
+}
+
+// Apply updated levels. Levels with a zero volume are removed.
+func (b *OrderBook) Update(asks, bids []PublicOrder) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, ask := range asks {
+		b.asks = updateSide(b.asks, ask, true, b.Depth)
+	}
+
+	for _, bid := range bids {
+		b.bids = updateSide(b.bids, bid, false, b.Depth)
+	}
+}
+
+// Returns the best (highest) bid.
+func (b *OrderBook) BestBid() (PublicOrder, bool) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	if len(b.bids) == 0 {
+		return PublicOrder{}, false
+	}
+
+	return b.bids[0], true
+}
+
+// Returns the best (lowest) ask.
+func (b *OrderBook) BestAsk() (PublicOrder, bool) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	if len(b.asks) == 0 {
+		return PublicOrder{}, false
+	}
+
+	return b.asks[0], true
+}
+
+func findLevel(side []PublicOrder, price float64, ask bool) (PublicOrder, bool) {
+	i := sort.Search(len(side), func(i int) bool { return !sideBefore(ask, side[i].Price, price) })
+	if i < len(side) && side[i].Price == price {
+		return side[i], true
+	}
+
+	return PublicOrder{}, false
+}
+
+// Returns the bid level at price.
+func (b *OrderBook) Bid(price float64) (PublicOrder, bool) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return findLevel(b.bids, price, false)
+}
+
+// Returns the ask level at price.
+func (b *OrderBook) Ask(price float64) (PublicOrder, bool) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return findLevel(b.asks, price, true)
+}
+
+// Returns the total volume of asks at or below price, ie: the volume a buy limit order at price can take.
+func (b *OrderBook) AskVolumeTo(price float64) float64 {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	volume := 0.0
+	for _, ask := range b.asks {
+		if ask.Price > price {
+			break
+		}
+		volume += ask.Volume
+	}
+
+	return volume
+}
+
+// Returns the total volume of bids at or above price, ie: the volume a sell limit order at price can take.
+func (b *OrderBook) BidVolumeTo(price float64) float64 {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	volume := 0.0
+	for _, bid := range b.bids {
+		if bid.Price < price {
+			break
+		}
+		volume += bid.Volume
+	}
+
+	return volume
+}
+
+// Returns a copy of the book, asks lowest price first and bids highest price first.
+// Its methods compute sweep costs, slippage, micro-price... (see PublicOrderBook.Sweep)
+func (b *OrderBook) Snapshot() PublicOrderBook {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	snapshot := PublicOrderBook{
+		Asks: make([]PublicOrder, len(b.asks)),
+		Bids: make([]PublicOrder, len(b.bids)),
+	}
+
+	copy(snapshot.Asks, b.asks)
+	copy(snapshot.Bids, b.bids)
+
+	return snapshot
+}
@@ -0,0 +1,84 @@
+package krakenapi
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestOrderBook(t *testing.T) {
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error":[],"result":{"XXBTZEUR":{
+			"asks":[["102.0","1.0",1],["101.0","2.0",1],["103.0","3.0",1]],
+			"bids":[["99.0","1.0",1],["100.0","2.0",1],["98.0","3.0",1]]
+		}}}`))
+	})
+	defer server.Close()
+
+	book, err := client.LoadOrderBook("XXBTZEUR", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ask, _ := book.BestAsk()
+	bid, _ := book.BestBid()
+	if ask.Price != 101 || bid.Price != 100 {
+		t.Errorf("Unexpected best ask %v and bid %v", ask, bid)
+	}
+
+	if volume := book.AskVolumeTo(102); volume != 3 {
+		t.Errorf("Unexpected ask volume to 102: %f", volume)
+	}
+
+	if volume := book.BidVolumeTo(99); volume != 3 {
+		t.Errorf("Unexpected bid volume to 99: %f", volume)
+	}
+
+	book.Update(
+		[]PublicOrder{{Price: 101, Volume: 0}, {Price: 100.5, Volume: 0.5}, {Price: 102, Volume: 4}},
+		[]PublicOrder{{Price: 100.2, Volume: 1}},
+	)
+
+	snapshot := book.Snapshot()
+	asks := []float64{100.5, 102, 103}
+	bids := []float64{100.2, 100, 99} // 98 is beyond the depth cap
+
+	if len(snapshot.Asks) != 3 || len(snapshot.Bids) != 3 {
+		t.Fatalf("Unexpected book %v", snapshot)
+	}
+
+	for i := range asks {
+		if snapshot.Asks[i].Price != asks[i] || snapshot.Bids[i].Price != bids[i] {
+			t.Errorf("Unexpected book %v", snapshot)
+		}
+	}
+
+	if level, ok := book.Ask(102); !ok || level.Volume != 4 {
+		t.Errorf("Unexpected level at 102: %v", level)
+	}
+
+	if _, ok := book.Bid(98); ok {
+		t.Error("Level at 98 should be removed by the depth cap")
+	}
+
+	// One writer, concurrent readers
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				book.BestAsk()
+				book.AskVolumeTo(105)
+				book.Snapshot()
+			}
+		}()
+	}
+
+	for j := 0; j < 1000; j++ {
+		book.Update([]PublicOrder{{Price: 104, Volume: float64(j % 2)}}, nil)
+	}
+
+	wg.Wait()
+}
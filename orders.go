@@ -1,10 +1,256 @@
 package krakenapi
 
 import (
+	"context"
+	"fmt"
 	"net/url"
 	"strconv"
+	"strings"
 )
 
+// How long an order remains in the book before being canceled.
+type TimeInForce string
+
+const (
+	TIF_GTC TimeInForce = "GTC" // good-til-canceled (default)
+	TIF_IOC TimeInForce = "IOC" // immediate-or-cancel
+	TIF_GTD TimeInForce = "GTD" // good-til-date, until Expiretm
+)
+
+// Self trade prevention: which order is canceled when two orders of the same
+// account would match.
+type StpType string
+
+const (
+	STP_CANCEL_NEWEST StpType = "cancel-newest" // default
+	STP_CANCEL_OLDEST StpType = "cancel-oldest"
+	STP_CANCEL_BOTH   StpType = "cancel-both"
+)
+
+// Price reference of triggered orders (stop-loss, take-profit, trailing-stop...).
+type TriggerReference string
+
+const (
+	TRIGGER_LAST  TriggerReference = "last" // last traded price (default)
+	TRIGGER_INDEX TriggerReference = "index"
+)
+
+// An order, as given to AddOrder, EditOrder or AmendOrder.
+// Zero values are not sent, except for Volume, and Price which is sent for all
+// orders but market and settle-position orders.
+type OrderRequest struct {
+	Pair        string           // asset pair
+	Type        string           // type of order (buy/sell)
+	OrderType   string           // order type (market/limit/stop-loss/...)
+	Price       float64          // price (dependent upon ordertype)
+	Price2      float64          // secondary price (dependent upon ordertype)
+	Volume      float64          // order volume in lots
+	Leverage    string           // amount of leverage desired
+	Oflags      string           // comma delimited list of order flags
+	PostOnly    bool             // post only order, added to Oflags.  limit orders only
+	TimeInForce TimeInForce      // time in force (GTC/IOC/GTD)
+	ReduceOnly  bool             // only reduce an open margin position
+	StpType     StpType          // self trade prevention
+	Trigger     TriggerReference // price reference of triggered orders (last/index)
+	Starttm     string           // scheduled start time (0, +<n> or unix timestamp)
+	Expiretm    string           // expiration time (0, +<n> or unix timestamp)
+	Userref     string           // user reference id.  32-bit signed number
+	ClOrdId     string           // client order id.  mutually exclusive with Userref
+	Validate    bool             // validate inputs only.  do not submit order
+}
+
+// Returned when an order request is not valid. The order is not sent.
+type OrderValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *OrderValidationError) Error() string {
+	return fmt.Sprintf("Invalid order %s: %s", e.Field, e.Reason)
+}
+
+// Order types with a trigger price.
+var triggeredOrderTypes = map[string]bool{
+	"stop-loss":           true,
+	"stop-loss-limit":     true,
+	"take-profit":         true,
+	"take-profit-limit":   true,
+	"trailing-stop":       true,
+	"trailing-stop-limit": true,
+}
+
+// Returns the order flags, including post if PostOnly is set.
+func (order *OrderRequest) flags() []string {
+	flags := []string{}
+	post := order.PostOnly
+
+	for _, flag := range strings.Split(order.Oflags, ",") {
+		if flag == "" {
+			continue
+		}
+		if flag == "post" {
+			post = false
+		}
+		flags = append(flags, flag)
+	}
+
+	if post {
+		flags = append(flags, "post")
+	}
+
+	return flags
+}
+
+// Returns whether the order is post only, from PostOnly or Oflags.
+func (order *OrderRequest) isPostOnly() bool {
+	for _, flag := range order.flags() {
+		if flag == "post" {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+Check the combinations of order options. Returns an *OrderValidationError if:
+
+- TimeInForce, StpType or Trigger has an unknown value,
+- the order is post only but has another type than limit, or is immediate-or-cancel,
+- the order is good-til-date without Expiretm, or has an Expiretm while not GTD,
+- Trigger is set on an order type without a trigger price,
+- the order is reduce only without leverage.
+*/
+func (order *OrderRequest) Check() error {
+	switch order.TimeInForce {
+	case "", TIF_GTC, TIF_IOC, TIF_GTD:
+	default:
+		return &OrderValidationError{"timeinforce", fmt.Sprintf("unknown time in force %s", order.TimeInForce)}
+	}
+
+	switch order.StpType {
+	case "", STP_CANCEL_NEWEST, STP_CANCEL_OLDEST, STP_CANCEL_BOTH:
+	default:
+		return &OrderValidationError{"stptype", fmt.Sprintf("unknown self trade prevention %s", order.StpType)}
+	}
+
+	switch order.Trigger {
+	case "", TRIGGER_LAST, TRIGGER_INDEX:
+	default:
+		return &OrderValidationError{"trigger", fmt.Sprintf("unknown trigger reference %s", order.Trigger)}
+	}
+
+	if order.isPostOnly() {
+		// Edits usually leave OrderType empty, the order keeping its type
+		if order.OrderType != "" && order.OrderType != "limit" {
+			return &OrderValidationError{"oflags", fmt.Sprintf("post only is not available for %s orders", order.OrderType)}
+		}
+		if order.TimeInForce == TIF_IOC {
+			return &OrderValidationError{"oflags", "post only orders can not be immediate-or-cancel"}
+		}
+	}
+
+	expires := order.Expiretm != "" && order.Expiretm != "0"
+
+	if order.TimeInForce == TIF_GTD && !expires {
+		return &OrderValidationError{"expiretm", "good-til-date orders require an expiration time"}
+	}
+
+	if expires && order.TimeInForce != "" && order.TimeInForce != TIF_GTD {
+		return &OrderValidationError{"expiretm", fmt.Sprintf("expiration time is not available for %s orders", order.TimeInForce)}
+	}
+
+	if order.Trigger != "" && !triggeredOrderTypes[order.OrderType] {
+		return &OrderValidationError{"trigger", fmt.Sprintf("%s orders have no trigger price", order.OrderType)}
+	}
+
+	if order.ReduceOnly && (order.Leverage == "" || order.Leverage == "none") {
+		return &OrderValidationError{"reduce_only", "reduce only is only available for margin orders"}
+	}
+
+	return nil
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// Returns the AddOrder parameters for this order.
+func (order *OrderRequest) Values() url.Values {
+	params := url.Values{}
+	params.Set("pair", order.Pair)
+	params.Set("type", order.Type)
+	params.Set("ordertype", order.OrderType)
+	if order.OrderType != "market" && order.OrderType != "settle-position" {
+		params.Set("price", formatFloat(order.Price))
+	}
+
+	params.Set("volume", formatFloat(order.Volume))
+
+	if order.Price2 != 0 {
+		params.Set("price2", formatFloat(order.Price2))
+	}
+
+	if order.Leverage != "" {
+		params.Set("leverage", order.Leverage)
+	}
+
+	if flags := order.flags(); len(flags) > 0 {
+		params.Set("oflags", strings.Join(flags, ","))
+	}
+
+	if order.TimeInForce != "" {
+		params.Set("timeinforce", string(order.TimeInForce))
+	}
+
+	if order.ReduceOnly {
+		params.Set("reduce_only", "true")
+	}
+
+	if order.StpType != "" {
+		params.Set("stptype", string(order.StpType))
+	}
+
+	if order.Trigger != "" {
+		params.Set("trigger", string(order.Trigger))
+	}
+
+	if order.Starttm != "" {
+		params.Set("starttm", order.Starttm)
+	}
+
+	if order.Expiretm != "" {
+		params.Set("expiretm", order.Expiretm)
+	}
+
+	if order.Userref != "" {
+		params.Set("userref", order.Userref)
+	}
+
+	if order.ClOrdId != "" {
+		params.Set("cl_ord_id", order.ClOrdId)
+	}
+
+	if order.Validate {
+		params.Set("validate", "true")
+	}
+
+	return params
+}
+
+// Returns the limit and trigger prices of the order, as understood by AmendOrder.
+// Orders without an order type are considered limit orders.
+func (order *OrderRequest) limitAndTriggerPrices() (float64, float64) {
+	switch {
+	case order.OrderType == "" || order.OrderType == "limit":
+		return order.Price, 0
+	case strings.HasSuffix(order.OrderType, "-limit"):
+		return order.Price2, order.Price
+	default:
+		return 0, order.Price
+	}
+}
+
 /*
 Input:
 
@@ -34,6 +280,18 @@ oflags = comma delimited list of order flags (optional):
     fciq = prefer fee in quote currency
     nompp = no market price protection
     post = post only order (available when ordertype = limit)
+timeinforce = time in force (optional):
+    GTC = good-til-canceled (default)
+    IOC = immediate-or-cancel
+    GTD = good-til-date (expiretm must be set)
+reduce_only = only reduce an open margin position (optional)
+stptype = self trade prevention (optional):
+    cancel-newest = cancel the arriving order (default)
+    cancel-oldest = cancel the resting order
+    cancel-both = cancel both orders
+trigger = price reference of triggered orders (optional):
+    last = last traded price (default)
+    index = index price
 starttm = scheduled start time (optional):
     0 = now (default)x
     +<n> = schedule start time <n> seconds from now
@@ -43,6 +301,7 @@ expiretm = expiration time (optional):
     +<n> = expire <n> seconds from now
     <n> = unix timestamp of expiration time
 userref = user reference id.  32-bit signed number.  (optional)
+cl_ord_id = client order id.  mutually exclusive with userref (optional)
 validate = validate inputs only.  do not submit order (optional)
 
 optional closing order to add to system when order gets filled:
@@ -57,25 +316,39 @@ descr = order description info
 txid = array of transaction ids for order (if order was added successfully)
 */
 func (api *KrakenApi) ApiAddOrder(pair, bstype, ordertype string, price, price2, volume float64, oflags string) (*OrderResult, error) {
-	params := url.Values{}
-	params.Set("pair", pair)
-	params.Set("type", bstype)
-	params.Set("ordertype", ordertype)
-	if ordertype != "market" {
-		params.Set("price", strconv.FormatFloat(price, 'f', -1, 64))
-	}
-
-	params.Set("volume", strconv.FormatFloat(volume, 'f', -1, 64))
-	// params.Set("validate", "1") /* Used  for debug */
+	return api.ApiAddOrderRequest(&OrderRequest{
+		Pair:      pair,
+		Type:      bstype,
+		OrderType: ordertype,
+		Price:     price,
+		Price2:    price2,
+		Volume:    volume,
+		Oflags:    oflags,
+	})
+}
 
-	if price2 != 0 {
-		params.Set("price2", strconv.FormatFloat(price, 'f', -1, 64))
+// Same as ApiAddOrder, using an OrderRequest to describe the order.
+// The order is checked with OrderRequest.Check before being sent.
+func (api *KrakenApi) ApiAddOrderRequest(order *OrderRequest) (*OrderResult, error) {
+	err := order.Check()
+	if err != nil {
+		return nil, err
 	}
 
-	if oflags != "" {
-		params.Set("oflags", oflags)
+	if api.PreTradeCheck != nil {
+		err = api.PreTradeCheck(order)
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	return api.addOrder(order)
+}
+
+// Send an order, without validation nor pre-trade checks.
+func (api *KrakenApi) addOrder(order *OrderRequest) (*OrderResult, error) {
+	params := order.Values()
+
 	resp, err := api.Query(URL_PRIVATE_ADD_ORDER, params, true)
 	if err != nil {
 		return nil, err
@@ -92,7 +365,7 @@ func (api *KrakenApi) ApiAddOrder(pair, bstype, ordertype string, price, price2,
 /*
 Input:
 
-txid = transaction id
+txid = transaction id or user reference id
 Result:
 
 count = number of orders canceled
@@ -114,3 +387,466 @@ func (api *KrakenApi) ApiCancelOrder(txid string) (*CancelResult, error) {
 
 	return content.(*CancelResult), err
 }
+
+/*
+URL: https://api.kraken.com/0/private/EditOrder
+
+Input:
+
+txid = transaction id or user reference id of the order to edit
+userref = user reference id (optional)
+pair = asset pair
+volume = order volume in lots (optional)
+price = price (optional.  dependent upon ordertype)
+price2 = secondary price (optional.  dependent upon ordertype)
+oflags = comma delimited list of order flags (optional)
+validate = validate inputs only.  do not submit order (optional)
+
+Result:
+
+descr = order description info
+    order = order description
+txid = new transaction id
+originaltxid = original transaction id
+volume = updated volume
+price = updated price
+price2 = updated price2
+orders_cancelled = number of orders cancelled (either 0 or 1)
+status = status of order: ok / err
+error_message = error message if unsuccessful
+Note: The original order is cancelled, and a new order with a new txid is created.
+      Queue priority is lost.
+
+The order is checked with OrderRequest.Check. Only the inputs above are sent: other
+options (time in force, reduce only, self trade prevention, trigger) can not be edited.
+*/
+func (api *KrakenApi) ApiEditOrder(txid string, order *OrderRequest) (*EditOrderResult, error) {
+	err := order.Check()
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("txid", txid)
+	params.Set("pair", order.Pair)
+
+	if order.Volume != 0 {
+		params.Set("volume", formatFloat(order.Volume))
+	}
+
+	if order.Price != 0 {
+		params.Set("price", formatFloat(order.Price))
+	}
+
+	if order.Price2 != 0 {
+		params.Set("price2", formatFloat(order.Price2))
+	}
+
+	if flags := order.flags(); len(flags) > 0 {
+		params.Set("oflags", strings.Join(flags, ","))
+	}
+
+	if order.Userref != "" {
+		params.Set("userref", order.Userref)
+	}
+
+	if order.Validate {
+		params.Set("validate", "true")
+	}
+
+	err = api.checkOrderChange(txid, order.editChange())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := api.Query(URL_PRIVATE_EDIT_ORDER, params, true)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := parse(resp, &EditOrderResult{})
+	if err != nil {
+		return nil, err
+	}
+
+	return content.(*EditOrderResult), nil
+}
+
+/*
+Run PreTradeCheck on the orders resulting from an edit or an amend of txid (a
+transaction id or a user reference id): the current orders are loaded, then
+changed by change. Only the volume left to execute is checked.
+
+The current orders being still open, limits on open orders count them as well.
+*/
+func (api *KrakenApi) checkOrderChange(txid string, change func(order *OrderRequest)) error {
+	if api.PreTradeCheck == nil {
+		return nil
+	}
+
+	var orders map[string]Order
+
+	if _, err := strconv.Atoi(txid); err == nil {
+		params := url.Values{}
+		params.Set("userref", txid)
+
+		open, err := api.openOrders(params)
+		if err != nil {
+			return fmt.Errorf("Could not check orders %s: %s", txid, err.Error())
+		}
+
+		orders = open.Open
+	} else {
+		queried, err := api.ApiQueryOrders(false, "", txid)
+		if err != nil {
+			return fmt.Errorf("Could not check order %s: %s", txid, err.Error())
+		}
+
+		orders = *queried
+	}
+
+	if len(orders) == 0 {
+		return fmt.Errorf("Could not check order %s: order not found", txid)
+	}
+
+	for _, current := range orders {
+		order := &OrderRequest{
+			Pair:      current.Descr.Pair,
+			Type:      current.Descr.Type,
+			OrderType: current.Descr.Ordertype,
+			Price:     current.Descr.Price,
+			Price2:    current.Descr.Price2,
+			Volume:    current.Vol,
+		}
+
+		change(order)
+		order.Volume -= current.VolExec
+
+		err := api.PreTradeCheck(order)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns a change applying the volume and prices of an EditOrder request.
+func (order *OrderRequest) editChange() func(*OrderRequest) {
+	return func(edited *OrderRequest) {
+		if order.Volume != 0 {
+			edited.Volume = order.Volume
+		}
+
+		if order.Price != 0 {
+			edited.Price = order.Price
+		}
+
+		if order.Price2 != 0 {
+			edited.Price2 = order.Price2
+		}
+	}
+}
+
+// Returns a change applying the volume, limit and trigger prices of an AmendOrder request.
+func (order *OrderRequest) amendChange() func(*OrderRequest) {
+	limit_price, trigger_price := order.limitAndTriggerPrices()
+
+	return func(amended *OrderRequest) {
+		if order.Volume != 0 {
+			amended.Volume = order.Volume
+		}
+
+		switch {
+		case amended.OrderType == "" || amended.OrderType == "limit":
+			if limit_price != 0 {
+				amended.Price = limit_price
+			}
+		case strings.HasSuffix(amended.OrderType, "-limit"):
+			if trigger_price != 0 {
+				amended.Price = trigger_price
+			}
+			if limit_price != 0 {
+				amended.Price2 = limit_price
+			}
+		default:
+			if trigger_price != 0 {
+				amended.Price = trigger_price
+			}
+		}
+	}
+}
+
+/*
+URL: https://api.kraken.com/0/private/AmendOrder
+
+Input:
+
+txid = transaction id of the order to amend
+order_qty = new order quantity in base asset (optional)
+limit_price = new limit price (optional)
+trigger_price = new trigger price (optional)
+
+Result:
+
+amend_id = unique identifier of the amend transaction
+Note: Unlike EditOrder, the order keeps its txid and queue priority where possible.
+*/
+func (api *KrakenApi) ApiAmendOrder(txid string, order *OrderRequest) (*AmendOrderResult, error) {
+	params := url.Values{}
+	params.Set("txid", txid)
+
+	if order.Volume != 0 {
+		params.Set("order_qty", formatFloat(order.Volume))
+	}
+
+	limit_price, trigger_price := order.limitAndTriggerPrices()
+
+	if limit_price != 0 {
+		params.Set("limit_price", formatFloat(limit_price))
+	}
+
+	if trigger_price != 0 {
+		params.Set("trigger_price", formatFloat(trigger_price))
+	}
+
+	err := api.checkOrderChange(txid, order.amendChange())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := api.Query(URL_PRIVATE_AMEND_ORDER, params, true)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := parse(resp, &AmendOrderResult{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := content.(*AmendOrderResult)
+	result.Txid = txid
+
+	return result, nil
+}
+
+// Run PreTradeBatchCheck on orders sent together, or PreTradeCheck on each of them if it is not set.
+func (api *KrakenApi) preTradeCheckBatch(orders []*OrderRequest) error {
+	if api.PreTradeBatchCheck != nil {
+		return api.PreTradeBatchCheck(orders)
+	}
+
+	if api.PreTradeCheck == nil {
+		return nil
+	}
+
+	for _, order := range orders {
+		err := api.PreTradeCheck(order)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Number of orders AddOrderBatch accepts.
+const (
+	ADD_ORDER_BATCH_MIN_ORDERS = 2
+	ADD_ORDER_BATCH_MAX_ORDERS = 15
+)
+
+/*
+URL: https://api.kraken.com/0/private/AddOrderBatch
+
+Input:
+
+orders = array of orders (2 minimum, 15 maximum).  See Add standard order.
+pair = asset pair, shared by all orders of the batch
+validate = validate inputs only.  do not submit orders (optional)
+
+Result:
+
+orders = array of order results, in the same order as the request
+    descr = order description info
+        order = order description
+    txid = transaction id for order (if order was added successfully)
+    error = error message (if order was rejected)
+
+An error is returned if the response does not have a result for each order: some
+orders may have been placed anyway.
+*/
+func (api *KrakenApi) ApiAddOrderBatch(pair string, orders []*OrderRequest, validate bool) ([]BatchOrder, error) {
+	if len(orders) < ADD_ORDER_BATCH_MIN_ORDERS || len(orders) > ADD_ORDER_BATCH_MAX_ORDERS {
+		return nil, fmt.Errorf("Invalid batch of %d orders: AddOrderBatch takes %d to %d orders", len(orders), ADD_ORDER_BATCH_MIN_ORDERS, ADD_ORDER_BATCH_MAX_ORDERS)
+	}
+
+	params := url.Values{}
+	params.Set("pair", pair)
+
+	if validate {
+		params.Set("validate", "true")
+	}
+
+	checked := make([]*OrderRequest, 0, len(orders))
+
+	for _, order := range orders {
+		if order.Pair != "" && order.Pair != pair {
+			return nil, fmt.Errorf("All orders of a batch must be on pair %s (got %s)", pair, order.Pair)
+		}
+
+		err := order.Check()
+		if err != nil {
+			return nil, err
+		}
+
+		with_pair := *order
+		with_pair.Pair = pair
+		checked = append(checked, &with_pair)
+	}
+
+	err := api.preTradeCheckBatch(checked)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, order := range orders {
+		for key, values := range order.Values() {
+			if key == "pair" || key == "validate" {
+				continue
+			}
+
+			params.Set(fmt.Sprintf("orders[%d][%s]", i, key), values[0])
+		}
+	}
+
+	resp, err := api.Query(URL_PRIVATE_ADD_ORDER_BATCH, params, true)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := parse(resp, &AddOrderBatchResult{})
+	if err != nil {
+		return nil, err
+	}
+
+	results := content.(*AddOrderBatchResult).Orders
+	if len(results) != len(orders) {
+		return nil, fmt.Errorf("Could not match AddOrderBatch results to orders: %d results for %d orders", len(results), len(orders))
+	}
+
+	out := make([]BatchOrder, 0, len(orders))
+
+	for _, order := range results {
+		if order.Error != "" {
+			out = append(out, BatchOrder{Err: fmt.Errorf("Order rejected! (%s)", order.Error)})
+			continue
+		}
+
+		result := &OrderResult{Txid: []string{order.Txid}}
+		result.Descr.Order = order.Descr.Order
+
+		out = append(out, BatchOrder{Result: result})
+	}
+
+	return out, nil
+}
+
+/*
+URL: https://api.kraken.com/0/private/CancelOrderBatch
+
+Input:
+
+orders = array of transaction ids or user reference ids to cancel (50 maximum)
+
+Result:
+
+count = number of orders canceled
+*/
+func (api *KrakenApi) ApiCancelOrderBatch(txids []string) (*CancelResult, error) {
+	params := url.Values{}
+
+	for i, txid := range txids {
+		params.Set(fmt.Sprintf("orders[%d]", i), txid)
+	}
+
+	resp, err := api.Query(URL_PRIVATE_CANCEL_ORDER_BATCH, params, true)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := parse(resp, &CancelResult{})
+	if err != nil {
+		return nil, err
+	}
+
+	return content.(*CancelResult), nil
+}
+
+/*
+Cancel all orders of a given user reference id.
+See ApiCancelOrder: CancelOrder accepts a user reference id in place of a txid.
+To cancel a list of transaction ids, see ApiCancelOrderBatch.
+*/
+func (api *KrakenApi) ApiCancelOrderUserref(userref string) (*CancelResult, error) {
+	return api.ApiCancelOrder(userref)
+}
+
+/*
+URL: https://api.kraken.com/0/private/CancelAll
+
+Result:
+
+count = number of orders canceled
+*/
+func (api *KrakenApi) ApiCancelAll() (*CancelResult, error) {
+	resp, err := api.Query(URL_PRIVATE_CANCEL_ALL, url.Values{}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := parse(resp, &CancelResult{})
+	if err != nil {
+		return nil, err
+	}
+
+	return content.(*CancelResult), nil
+}
+
+/*
+URL: https://api.kraken.com/0/private/CancelAllOrdersAfter
+
+Input:
+
+timeout = duration (in seconds) to set/extend the timer by.  0 disables the timer.
+
+Result:
+
+currentTime = timestamp (RFC3339 format) at which the request was received
+triggerTime = timestamp (RFC3339 format) after which all orders will be cancelled,
+              unless the timer is extended or disabled
+Note: This is a "dead man's switch": the call should be repeated well before
+      the timeout expires. See DeadManSwitch.
+*/
+func (api *KrakenApi) ApiCancelAllOrdersAfter(timeout int) (*CancelAllAfterResult, error) {
+	return api.cancelAllOrdersAfter(context.Background(), timeout)
+}
+
+// Same as ApiCancelAllOrdersAfter, the call being aborted once ctx is done.
+func (api *KrakenApi) cancelAllOrdersAfter(ctx context.Context, timeout int) (*CancelAllAfterResult, error) {
+	params := url.Values{}
+	params.Set("timeout", strconv.Itoa(timeout))
+
+	resp, err := api.queryContext(ctx, URL_PRIVATE_CANCEL_ALL_AFTER, params, true)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := parse(resp, &CancelAllAfterResult{})
+	if err != nil {
+		return nil, err
+	}
+
+	return content.(*CancelAllAfterResult), nil
+}
@@ -0,0 +1,202 @@
+package krakenapi
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestOrderRequestCheck(t *testing.T) {
+	valid := []OrderRequest{
+		{OrderType: "limit", PostOnly: true},
+		{OrderType: "limit", Oflags: "post,fciq", TimeInForce: TIF_GTC},
+		{OrderType: "limit", TimeInForce: TIF_GTD, Expiretm: "+60"},
+		{OrderType: "limit", Expiretm: "+60"},
+		{OrderType: "market", TimeInForce: TIF_IOC},
+		{OrderType: "stop-loss", Trigger: TRIGGER_INDEX},
+		{OrderType: "market", Leverage: "2", ReduceOnly: true},
+		{OrderType: "limit", StpType: STP_CANCEL_BOTH},
+	}
+
+	for _, order := range valid {
+		err := order.Check()
+		if err != nil {
+			t.Errorf("%+v should be valid: %s", order, err)
+		}
+	}
+
+	invalid := map[string]OrderRequest{
+		"oflags":      {OrderType: "market", PostOnly: true},
+		"expiretm":    {OrderType: "limit", TimeInForce: TIF_GTD},
+		"trigger":     {OrderType: "limit", Trigger: TRIGGER_LAST},
+		"reduce_only": {OrderType: "limit", ReduceOnly: true},
+		"stptype":     {OrderType: "limit", StpType: "cancel-all"},
+		"timeinforce": {OrderType: "limit", TimeInForce: "FOK"},
+	}
+
+	for field, order := range invalid {
+		err := order.Check()
+		if e, ok := err.(*OrderValidationError); !ok || e.Field != field {
+			t.Errorf("%+v should be refused on %s, got %v", order, field, err)
+		}
+	}
+
+	invalid_combinations := []OrderRequest{
+		{OrderType: "stop-loss-limit", Oflags: "post"},
+		{OrderType: "limit", PostOnly: true, TimeInForce: TIF_IOC},
+		{OrderType: "limit", TimeInForce: TIF_IOC, Expiretm: "+60"},
+		{OrderType: "limit", Leverage: "none", ReduceOnly: true},
+	}
+
+	for _, order := range invalid_combinations {
+		if order.Check() == nil {
+			t.Errorf("%+v should be refused", order)
+		}
+	}
+}
+
+func TestOrderRequestOptions(t *testing.T) {
+	order := &OrderRequest{
+		Pair:        "XXBTZEUR",
+		Type:        "buy",
+		OrderType:   "limit",
+		Price:       100,
+		Volume:      1,
+		Leverage:    "2",
+		Oflags:      "fciq",
+		PostOnly:    true,
+		TimeInForce: TIF_GTD,
+		Expiretm:    "+60",
+		ReduceOnly:  true,
+		StpType:     STP_CANCEL_OLDEST,
+	}
+
+	params := order.Values()
+
+	expected := map[string]string{
+		"oflags":      "fciq,post",
+		"timeinforce": "GTD",
+		"expiretm":    "+60",
+		"reduce_only": "true",
+		"stptype":     "cancel-oldest",
+		"trigger":     "",
+	}
+
+	for key, value := range expected {
+		if params.Get(key) != value {
+			t.Errorf("Unexpected %s: %q instead of %q", key, params.Get(key), value)
+		}
+	}
+
+	// post is not repeated when already in Oflags
+	order.Oflags = "post"
+	if params := order.Values(); params.Get("oflags") != "post" {
+		t.Errorf("Unexpected oflags: %s", params.Get("oflags"))
+	}
+
+	queried := false
+
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		queried = true
+		w.Write([]byte(`{"error":[],"result":{}}`))
+	})
+	defer server.Close()
+
+	_, err := client.ApiAddOrderRequest(&OrderRequest{Pair: "XXBTZEUR", Type: "buy", OrderType: "market", Volume: 1, PostOnly: true})
+	if _, ok := err.(*OrderValidationError); !ok || queried {
+		t.Errorf("Invalid order should not be sent, got %v", err)
+	}
+}
+
+func TestEditOrderParams(t *testing.T) {
+	var sent url.Values
+
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sent = r.Form
+		w.Write([]byte(`{"error":[],"result":{"txid":"ONEW","originaltxid":"OTXID","status":"ok"}}`))
+	})
+	defer server.Close()
+
+	order := &OrderRequest{Pair: "XXBTZEUR", OrderType: "limit", Price: 100, Volume: 1, PostOnly: true, TimeInForce: TIF_GTC, StpType: STP_CANCEL_BOTH}
+
+	_, err := client.ApiEditOrder("OTXID", order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"timeinforce", "reduce_only", "stptype", "trigger"} {
+		if _, ok := sent[key]; ok {
+			t.Errorf("EditOrder does not accept %s", key)
+		}
+	}
+
+	if sent.Get("oflags") != "post" || sent.Get("price") != "100" || sent.Get("volume") != "1" {
+		t.Errorf("Unexpected parameters %v", sent)
+	}
+
+	// Edits usually leave the order type out
+	_, err = client.ApiEditOrder("OTXID", &OrderRequest{Pair: "XXBTZEUR", Price: 101, PostOnly: true})
+	if err != nil {
+		t.Fatalf("Post only edit without order type should be sent: %s", err)
+	}
+
+	if sent.Get("oflags") != "post" || sent.Get("price") != "101" {
+		t.Errorf("Unexpected parameters %v", sent)
+	}
+
+	sent = nil
+
+	_, err = client.ApiEditOrder("OTXID", &OrderRequest{Pair: "XXBTZEUR", OrderType: "limit", TimeInForce: TIF_GTD})
+	if _, ok := err.(*OrderValidationError); !ok || sent != nil {
+		t.Errorf("Invalid edit should not be sent, got %v", err)
+	}
+}
+
+func TestAddOrderBatchCount(t *testing.T) {
+	queries := 0
+
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		queries++
+		w.Write([]byte(`{"error":[],"result":{"orders":[{"txid":"OAAA","descr":{"order":"buy 0.1 XBTEUR @ limit 1"}}]}}`))
+	})
+	defer server.Close()
+
+	order := &OrderRequest{Type: "buy", OrderType: "limit", Price: 1, Volume: 0.1}
+
+	for _, count := range []int{1, 16} {
+		orders := make([]*OrderRequest, count)
+		for i := range orders {
+			orders[i] = order
+		}
+
+		_, err := client.ApiAddOrderBatch("XXBTZEUR", orders, false)
+		if err == nil || queries != 0 {
+			t.Errorf("Batch of %d orders should be refused before being sent", count)
+		}
+	}
+
+	_, err := client.ApiAddOrderBatch("XXBTZEUR", []*OrderRequest{order, order}, false)
+	if err == nil || queries != 1 {
+		t.Errorf("A single result for 2 orders should be an error, got %v", err)
+	}
+}
+
+func TestCancelAll(t *testing.T) {
+	path := ""
+
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte(`{"error":[],"result":{"count":3}}`))
+	})
+	defer server.Close()
+
+	result, err := client.ApiCancelAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path != URL_PRIVATE_CANCEL_ALL || result.Count != 3 {
+		t.Errorf("Unexpected cancel all: %s %+v", path, result)
+	}
+}
@@ -51,6 +51,30 @@ func (api *KrakenApi) ApiBalance() (map[string]float64, error) {
 	return balance, nil
 }
 
+/*
+URL: https://api.kraken.com/0/private/BalanceEx
+
+Result: array of asset names and extended balance info
+
+balance = total balance of the asset
+hold_trade = amount of the asset held by open orders
+*/
+func (api *KrakenApi) ApiBalanceEx() (map[string]BalanceEx, error) {
+	resp, err := api.Query(URL_PRIVATE_BALANCE_EX, url.Values{}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var balances map[string]BalanceEx
+
+	_, err = parse(resp, &balances)
+	if err != nil {
+		return nil, err
+	}
+
+	return balances, nil
+}
+
 /*
 Input:
 
@@ -94,6 +118,7 @@ Input:
 
 trades = whether or not to include trades in output (optional.  default = false)
 userref = restrict results to given user reference id (optional)
+cl_ord_id = restrict results to given client order id (optional)
 Result: array of order info in open array with txid as the key
 
 refid = Referral order transaction id that created this order
@@ -149,6 +174,10 @@ func (api *KrakenApi) ApiOpenOrders(trades bool, userref string) (*OpenOrders, e
 		params.Set("userref", userref)
 	}
 
+	return api.openOrders(params)
+}
+
+func (api *KrakenApi) openOrders(params url.Values) (*OpenOrders, error) {
 	resp, err := api.Query(URL_PRIVATE_OPEN_ORDERS, params, true)
 	if err != nil {
 		return nil, err
@@ -169,6 +198,7 @@ Input:
 
 trades = whether or not to include trades in output (optional.  default = false)
 userref = restrict results to given user reference id (optional)
+cl_ord_id = restrict results to given client order id (optional)
 start = starting unix timestamp or order tx id of results (optional.  exclusive)
 end = ending unix timestamp or order tx id of results (optional.  inclusive)
 ofs = result offset
@@ -211,6 +241,10 @@ func (api *KrakenApi) ApiClosedOrders(trades bool, userref, start, end string, o
 		params.Set("closetime", closetime)
 	}
 
+	return api.closedOrders(params)
+}
+
+func (api *KrakenApi) closedOrders(params url.Values) (*ClosedOrders, error) {
 	resp, err := api.Query(URL_PRIVATE_CLOSED_ORDERS, params, true)
 	if err != nil {
 		return nil, err
@@ -539,3 +573,30 @@ func (api *KrakenApi) ApiTradeVolume(pair string, feeinfo bool) (*TradeVolume, e
 
 	return out, nil
 }
+
+/*
+URL: https://api.kraken.com/0/private/GetWebSocketsToken
+
+Result:
+
+token = token to authenticate private WebSocket subscriptions
+expires = time (in seconds) after which the token expires
+
+Note: the token must be used within 15 minutes of its creation, but does not expire while a
+WebSocket connection with a private subscription using it stays open.
+*/
+func (api *KrakenApi) ApiGetWebSocketsToken() (*WebSocketsToken, error) {
+	resp, err := api.Query(URL_PRIVATE_WEBSOCKETS_TOKEN, url.Values{}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	out := new(WebSocketsToken)
+
+	_, err = parse(resp, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
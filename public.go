@@ -1,6 +1,8 @@
 package krakenapi
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/url"
 	"strconv"
 	"strings"
@@ -161,7 +163,7 @@ URL: https://api.kraken.com/0/public/OHLC
 Input:
 
 pair = asset pair to get OHLC data for
-interval = time frame interval in minutes (optional):
+interval = time frame interval in minutes (optional.  see OHLC_* constants):
 	1 (default), 5, 15, 30, 60, 240, 1440, 10080, 21600
 since = return committed OHLC data since given id (optional.  exclusive)
 Result: array of pair name and OHLC data
@@ -172,89 +174,61 @@ last = id to be used as since when polling for new, committed OHLC data
 Note: the last entry in the OHLC array is for the current, not-yet-committed frame and will always
       be present, regardless of the value of "since".
 */
-func (api *KrakenApi) ApiOHLC(pair string, interval int, since uint64) (float64, []OHLCEntry, error) {
+func (api *KrakenApi) ApiOHLC(pair string, interval OHLCInterval, since int64) (*OHLCResult, error) {
+	if interval != 0 && !interval.Valid() {
+		return nil, fmt.Errorf("Invalid OHLC interval %d", interval)
+	}
+
 	params := url.Values{}
 	params.Set("pair", pair)
 
 	if interval != 0 {
-		params.Set("interval", strconv.Itoa(interval))
+		params.Set("interval", strconv.Itoa(int(interval)))
 	}
 
 	if since != 0 {
-		params.Set("since", strconv.FormatUint(since, 10))
+		params.Set("since", strconv.FormatInt(since, 10))
 	}
 
 	resp, err := api.Query(URL_PUBLIC_OHLC, params, false)
 	if err != nil {
-		return 0, nil, err
+		return nil, err
 	}
 
-	content, err := parse(resp, nil)
+	var content map[string]json.RawMessage
+
+	_, err = parse(resp, &content)
 	if err != nil {
-		return 0, nil, err
+		return nil, err
 	}
 
-	var last float64
-	ohlc_data := make([]OHLCEntry, 0)
+	result := &OHLCResult{Pairs: make(map[string][]OHLCEntry)}
 
-	// Don't have much choice as returned json contains arrays, not structures...
-	for key, value := range content.(map[string]interface{}) {
+	for key, value := range content {
 		if key == "last" {
-			last = value.(float64)
-		} else {
-			for _, subvalue := range value.([]interface{}) {
-				values := make([]interface{}, len(subvalue.([]interface{})))
-				for i, subsubvalue := range subvalue.([]interface{}) {
-					values[i] = subsubvalue
-				}
-
-				open, err := strconv.ParseFloat(values[1].(string), 64)
-				if err != nil {
-					return 0, nil, err
-				}
-
-				high, err := strconv.ParseFloat(values[2].(string), 64)
-				if err != nil {
-					return 0, nil, err
-				}
-
-				low, err := strconv.ParseFloat(values[3].(string), 64)
-				if err != nil {
-					return 0, nil, err
-				}
-
-				close, err := strconv.ParseFloat(values[4].(string), 64)
-				if err != nil {
-					return 0, nil, err
-				}
-
-				vwap, err := strconv.ParseFloat(values[5].(string), 64)
-				if err != nil {
-					return 0, nil, err
-				}
-
-				volume, err := strconv.ParseFloat(values[6].(string), 64)
-				if err != nil {
-					return 0, nil, err
-				}
-
-				entry := OHLCEntry{
-					Time:   values[0].(float64),
-					Open:   open,
-					High:   high,
-					Low:    low,
-					Close:  close,
-					VWAP:   vwap,
-					Volume: volume,
-					Count:  values[7].(float64),
-				}
-
-				ohlc_data = append(ohlc_data, entry)
+			err = json.Unmarshal(value, &result.Last)
+			if err != nil {
+				return nil, err
 			}
+			continue
+		}
+
+		var entries []OHLCEntry
+
+		err = json.Unmarshal(value, &entries)
+		if err != nil {
+			return nil, err
+		}
+
+		// The last entry is the current, not-yet-committed frame.
+		for i := 0; i < len(entries)-1; i++ {
+			entries[i].Committed = true
 		}
+
+		result.Pairs[key] = entries
 	}
 
-	return last, ohlc_data, nil
+	return result, nil
 }
 
 /*
@@ -301,10 +275,25 @@ since = return trade data since given id (optional.  exclusive)
 Result: array of pair name and recent trade data
 
 <pair_name> = pair name
-    array of array entries(<price>, <volume>, <time>, <buy/sell>, <market/limit>, <miscellaneous>)
+    array of array entries(<price>, <volume>, <time>, <buy/sell>, <market/limit>, <miscellaneous>, <trade id>)
 last = id to be used as since when polling for new trade data
 */
 func (api *KrakenApi) ApiTrades(pair string, since string) (map[string][]RecentTrade, float64, error) {
+	out, last, err := api.trades(pair, since)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	last_id, err := strconv.ParseFloat(last, 64)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return out, last_id, nil
+}
+
+// Same as ApiTrades, returning last as sent by Kraken: nanosecond ids do not fit in a float64.
+func (api *KrakenApi) trades(pair string, since string) (map[string][]RecentTrade, string, error) {
 	params := url.Values{}
 	params.Set("pair", pair)
 
@@ -314,55 +303,39 @@ func (api *KrakenApi) ApiTrades(pair string, since string) (map[string][]RecentT
 
 	resp, err := api.Query(URL_PUBLIC_RECENT_TRADES, params, false)
 	if err != nil {
-		return nil, 0, err
+		return nil, "", err
 	}
 
-	content, err := parse(resp, nil)
+	var content map[string]json.RawMessage
+
+	_, err = parse(resp, &content)
 	if err != nil {
-		return nil, 0, err
+		return nil, "", err
 	}
 
-	var last float64
+	var last json.Number
 	out := make(map[string][]RecentTrade)
 
-	for key, value := range content.(map[string]interface{}) {
+	for key, value := range content {
 		if key == "last" {
-			last, err = strconv.ParseFloat(value.(string), 64)
+			err = json.Unmarshal(value, &last)
 			if err != nil {
-				return nil, 0, err
+				return nil, "", err
 			}
-			break
+			continue
 		}
 
-		trades := make([]RecentTrade, 0)
+		var trades []RecentTrade
 
-		for _, subvalue := range value.([]interface{}) {
-			values := subvalue.([]interface{})
-
-			price, err := strconv.ParseFloat(values[0].(string), 64)
-			if err != nil {
-				return nil, 0, err
-			}
-
-			volume, err := strconv.ParseFloat(values[1].(string), 64)
-			if err != nil {
-				return nil, 0, err
-			}
-
-			trades = append(trades, RecentTrade{
-				Price:     price,
-				Volume:    volume,
-				Time:      values[2].(float64),
-				Type:      values[3].(string),
-				TradeType: values[4].(string),
-				Misc:      values[5].(string),
-			})
+		err = json.Unmarshal(value, &trades)
+		if err != nil {
+			return nil, "", err
 		}
 
 		out[key] = trades
 	}
 
-	return out, last, err
+	return out, last.String(), nil
 }
 
 /*
@@ -409,12 +382,12 @@ func (api *KrakenApi) ApiSpread(pair string, since string) (map[string][]Spread,
 		for _, subvalue := range value.([]interface{}) {
 			values := subvalue.([]interface{})
 
-			ask, err := strconv.ParseFloat(values[1].(string), 64)
+			bid, err := strconv.ParseFloat(values[1].(string), 64)
 			if err != nil {
 				return nil, 0, err
 			}
 
-			bid, err := strconv.ParseFloat(values[2].(string), 64)
+			ask, err := strconv.ParseFloat(values[2].(string), 64)
 			if err != nil {
 				return nil, 0, err
 			}
@@ -0,0 +1,72 @@
+package krakenapi
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+/*
+RateLimiter spaces calls by Interval on average, allowing bursts of up to Burst
+calls. Set KrakenApi.RateLimiter to make Query wait for it before each call.
+
+Kraken allows about one public call per second; private call limits depend on
+the account's verification tier.
+*/
+type RateLimiter struct {
+	Interval time.Duration // average delay between two calls
+	Burst    int           // number of calls allowed at once
+
+	mutex sync.Mutex
+	next  time.Time // time at which a call is allowed with an empty burst
+}
+
+func NewRateLimiter(interval time.Duration, burst int) *RateLimiter {
+	return &RateLimiter{
+		Interval: interval,
+		Burst:    burst,
+	}
+}
+
+// Reserve a call, returning how long to wait before making it.
+func (l *RateLimiter) reserve() time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+
+	burst := l.Burst
+	if burst < 1 {
+		burst = 1
+	}
+
+	delay := l.next.Add(-time.Duration(burst-1) * l.Interval).Sub(now)
+	l.next = l.next.Add(l.Interval)
+
+	if delay < 0 {
+		return 0
+	}
+
+	return delay
+}
+
+// Wait until a call is allowed, or the context is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	delay := l.reserve()
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
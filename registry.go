@@ -0,0 +1,380 @@
+package krakenapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Common names used outside of Kraken for some assets, mapped to Kraken's altname.
+var commonAssetNames = map[string]string{
+	"BTC":  "XBT",
+	"DOGE": "XDG",
+}
+
+// Registry caches assets & asset pairs metadata and resolves the various names
+// Kraken uses for them (XXBTZEUR, XBTEUR, XBT/EUR, BTC/EUR...) to canonical names.
+type Registry struct {
+	api             *KrakenApi
+	RefreshInterval time.Duration // 0 means metadata is only fetched once
+
+	mutex        sync.RWMutex
+	assets       map[string]Asset
+	pairs        map[string]AssetPair
+	assetAliases map[string]string // normalized alias -> canonical asset name
+	pairAliases  map[string]string // normalized alias -> canonical pair name
+	pairOrder    []string          // canonical pair names, by preference when several share base & quote
+	updated      time.Time
+}
+
+// Create a new Registry using api to fetch metadata.
+// Metadata is fetched on first use, then every refresh interval.
+func NewRegistry(api *KrakenApi, refresh time.Duration) *Registry {
+	return &Registry{
+		api:             api,
+		RefreshInterval: refresh,
+	}
+}
+
+func normalizeName(name string) string {
+	return strings.ToUpper(strings.TrimSpace(name))
+}
+
+// Fetch assets and asset pairs from the api, regardless of the refresh interval.
+func (r *Registry) Refresh() error {
+	assets, err := r.api.ApiAssets()
+	if err != nil {
+		return err
+	}
+
+	pairs, err := r.api.ApiAssetPairs("", "")
+	if err != nil {
+		return err
+	}
+
+	r.load(assets, pairs)
+
+	return nil
+}
+
+// Returns whether a pair is named after its base & quote altnames (ie: XBTEUR or XBT/EUR).
+func isNamedPair(assets map[string]Asset, pair AssetPair) bool {
+	base, quote := assets[pair.Base].Altname, assets[pair.Quote].Altname
+
+	return base != "" && quote != "" && (pair.Altname == base+quote || pair.Wsname == base+"/"+quote)
+}
+
+// Returns the names of pairs, those named after their base & quote first, then sorted.
+// Aliases shared by several pairs go to the first one.
+func pairOrder(assets map[string]Asset, pairs map[string]AssetPair) []string {
+	names := make([]string, 0, len(pairs))
+	for name := range pairs {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		named_i, named_j := isNamedPair(assets, pairs[names[i]]), isNamedPair(assets, pairs[names[j]])
+		if named_i != named_j {
+			return named_i
+		}
+
+		return names[i] < names[j]
+	})
+
+	return names
+}
+
+func (r *Registry) load(assets map[string]Asset, pairs map[string]AssetPair) {
+	asset_aliases := make(map[string]string)
+	pair_aliases := make(map[string]string)
+
+	asset_names := make([]string, 0, len(assets))
+	for name := range assets {
+		asset_names = append(asset_names, name)
+	}
+	sort.Strings(asset_names)
+
+	pair_names := pairOrder(assets, pairs)
+
+	// Canonical names first, so that an alias never shadows a canonical name.
+	for _, name := range asset_names {
+		asset_aliases[normalizeName(name)] = name
+	}
+	for _, name := range asset_names {
+		addAlias(asset_aliases, assets[name].Altname, name)
+	}
+	for common, altname := range commonAssetNames {
+		if name, ok := asset_aliases[altname]; ok {
+			addAlias(asset_aliases, common, name)
+		}
+	}
+
+	names_of := make(map[string][]string)
+	for alias, name := range asset_aliases {
+		names_of[name] = append(names_of[name], alias)
+	}
+
+	for _, name := range pair_names {
+		pair_aliases[normalizeName(name)] = name
+	}
+	for _, name := range pair_names {
+		addAlias(pair_aliases, pairs[name].Altname, name)
+		addAlias(pair_aliases, pairs[name].Wsname, name)
+	}
+	for _, name := range pair_names {
+		// Dark pool pairs share base & quote with their regular pair
+		if strings.HasSuffix(name, ".d") {
+			continue
+		}
+
+		pair := pairs[name]
+
+		for _, base := range names_of[pair.Base] {
+			for _, quote := range names_of[pair.Quote] {
+				addAlias(pair_aliases, base+quote, name)
+				addAlias(pair_aliases, base+"/"+quote, name)
+			}
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.assets = assets
+	r.pairs = pairs
+	r.assetAliases = asset_aliases
+	r.pairAliases = pair_aliases
+	r.pairOrder = pair_names
+	r.updated = time.Now()
+}
+
+func addAlias(aliases map[string]string, alias, name string) {
+	alias = normalizeName(alias)
+	if alias == "" {
+		return
+	}
+
+	if _, ok := aliases[alias]; !ok {
+		aliases[alias] = name
+	}
+}
+
+func (r *Registry) ensureLoaded() error {
+	r.mutex.RLock()
+	loaded := r.pairs != nil
+	stale := r.RefreshInterval != 0 && time.Since(r.updated) > r.RefreshInterval
+	r.mutex.RUnlock()
+
+	if loaded && !stale {
+		return nil
+	}
+
+	err := r.Refresh()
+	if err != nil && loaded {
+		// Keep on using the stale metadata rather than failing.
+		return nil
+	}
+
+	return err
+}
+
+// Returns the canonical name of an asset (ie: BTC, XBT or XXBT -> XXBT)
+func (r *Registry) ResolveAsset(name string) (string, error) {
+	err := r.ensureLoaded()
+	if err != nil {
+		return "", err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	canonical, ok := r.assetAliases[normalizeName(name)]
+	if !ok {
+		return "", fmt.Errorf("Unknown asset %s", name)
+	}
+
+	return canonical, nil
+}
+
+// Returns the canonical name of a pair (ie: BTC/EUR, XBTEUR or XXBTZEUR -> XXBTZEUR)
+func (r *Registry) ResolvePair(name string) (string, error) {
+	err := r.ensureLoaded()
+	if err != nil {
+		return "", err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	normalized := normalizeName(name)
+
+	if canonical, ok := r.pairAliases[normalized]; ok {
+		return canonical, nil
+	}
+
+	// Try resolving each side of "BASE/QUOTE", "BASE-QUOTE" or "BASE_QUOTE".
+	for _, sep := range []string{"/", "-", "_"} {
+		parts := strings.Split(normalized, sep)
+		if len(parts) != 2 {
+			continue
+		}
+
+		base, ok := r.assetAliases[parts[0]]
+		if !ok {
+			break
+		}
+
+		quote, ok := r.assetAliases[parts[1]]
+		if !ok {
+			break
+		}
+
+		// Pairs named after base & quote first, the others sorted by name
+		for _, pair_name := range r.pairOrder {
+			pair := r.pairs[pair_name]
+			if pair.Base == base && pair.Quote == quote && !strings.HasSuffix(pair_name, ".d") {
+				return pair_name, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("Unknown pair %s", name)
+}
+
+// Returns the canonical names of the given pairs, as well as a map to convert
+// canonical names back to the names the caller used. When several names are
+// aliases of the same pair, the map has all of them, in the given order.
+func (r *Registry) ResolvePairs(names []string) ([]string, map[string][]string, error) {
+	canonicals := make([]string, 0, len(names))
+	aliases := make(map[string][]string)
+
+	for _, name := range names {
+		canonical, err := r.ResolvePair(name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		canonicals = append(canonicals, canonical)
+		aliases[canonical] = append(aliases[canonical], name)
+	}
+
+	return canonicals, aliases, nil
+}
+
+/*
+Returns the symbol of a pair on the v2 WebSocket feed (ie: XXBTZEUR, XBTEUR or
+XBT/EUR -> BTC/EUR): its WebSocket name, with the common names of assets Kraken
+renamed in v2 (XBT -> BTC, XDG -> DOGE).
+*/
+func (r *Registry) SymbolV2(name string) (string, error) {
+	pair, err := r.Pair(name)
+	if err != nil {
+		return "", err
+	}
+
+	sides := strings.Split(pair.Wsname, "/")
+	if len(sides) != 2 {
+		return "", fmt.Errorf("Pair %s has no WebSocket name", name)
+	}
+
+	for i, side := range sides {
+		for common, altname := range commonAssetNames {
+			if side == altname {
+				sides[i] = common
+			}
+		}
+	}
+
+	return sides[0] + "/" + sides[1], nil
+}
+
+// Returns asset metadata for any alias of an asset.
+func (r *Registry) Asset(name string) (Asset, error) {
+	canonical, err := r.ResolveAsset(name)
+	if err != nil {
+		return Asset{}, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.assets[canonical], nil
+}
+
+// Returns asset pair metadata for any alias of a pair.
+func (r *Registry) Pair(name string) (AssetPair, error) {
+	canonical, err := r.ResolvePair(name)
+	if err != nil {
+		return AssetPair{}, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.pairs[canonical], nil
+}
+
+// Same as ApiTicker, but accepts any pair alias and returns tickers keyed by
+// the names given by the caller.
+func (r *Registry) Ticker(pairs []string) (map[string]Ticker, error) {
+	canonicals, aliases, err := r.ResolvePairs(pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	tickers, err := r.api.ApiTicker(canonicals)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]Ticker)
+	for name, ticker := range tickers {
+		for _, alias := range r.aliasesFor(aliases, name) {
+			out[alias] = ticker
+		}
+	}
+
+	return out, nil
+}
+
+// Same as ApiDepth, but accepts any pair alias and returns the order book keyed
+// by the name given by the caller.
+func (r *Registry) Depth(pair string, count int) (map[string]PublicOrderBook, error) {
+	canonicals, aliases, err := r.ResolvePairs([]string{pair})
+	if err != nil {
+		return nil, err
+	}
+
+	books, err := r.api.ApiDepth(canonicals[0], count)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]PublicOrderBook)
+	for name, book := range books {
+		for _, alias := range r.aliasesFor(aliases, name) {
+			out[alias] = book
+		}
+	}
+
+	return out, nil
+}
+
+// Returns the caller's aliases for a pair name returned by the api. Kraken does not
+// always answer with the canonical name, so the returned name is resolved again.
+func (r *Registry) aliasesFor(aliases map[string][]string, name string) []string {
+	if names, ok := aliases[name]; ok {
+		return names
+	}
+
+	canonical, err := r.ResolvePair(name)
+	if err == nil {
+		if names, ok := aliases[canonical]; ok {
+			return names
+		}
+	}
+
+	return []string{name}
+}
@@ -0,0 +1,123 @@
+package krakenapi
+
+import (
+	"testing"
+)
+
+func createTestRegistry() *Registry {
+	registry := NewRegistry(nil, 0)
+
+	assets := map[string]Asset{
+		"XXBT": {Altname: "XBT", Aclass: "currency", Decimals: 10, DisplayDecimals: 5},
+		"ZEUR": {Altname: "EUR", Aclass: "currency", Decimals: 4, DisplayDecimals: 2},
+		"DASH": {Altname: "DASH", Aclass: "currency", Decimals: 10, DisplayDecimals: 5},
+	}
+
+	pairs := map[string]AssetPair{
+		"XXBTZEUR":   {Altname: "XBTEUR", Wsname: "XBT/EUR", Base: "XXBT", Quote: "ZEUR"},
+		"XXBTZEUR.d": {Altname: "XBTEUR.d", Base: "XXBT", Quote: "ZEUR"},
+		"DASHEUR":    {Altname: "DASHEUR", Wsname: "DASH/EUR", Base: "DASH", Quote: "ZEUR"},
+	}
+
+	registry.load(assets, pairs)
+
+	return registry
+}
+
+func TestRegistryResolveAsset(t *testing.T) {
+	registry := createTestRegistry()
+
+	for _, name := range []string{"XXBT", "XBT", "BTC", "btc"} {
+		canonical, err := registry.ResolveAsset(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if canonical != "XXBT" {
+			t.Errorf("%s resolved to %s instead of XXBT", name, canonical)
+		}
+	}
+
+	_, err := registry.ResolveAsset("UNKNOWN")
+	if err == nil {
+		t.Error("Resolving an unknown asset should fail")
+	}
+}
+
+func TestRegistryResolvePair(t *testing.T) {
+	registry := createTestRegistry()
+
+	for _, name := range []string{"XXBTZEUR", "XBTEUR", "XBT/EUR", "BTC/EUR", "BTCEUR", "btc-eur", "XBT_ZEUR"} {
+		canonical, err := registry.ResolvePair(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if canonical != "XXBTZEUR" {
+			t.Errorf("%s resolved to %s instead of XXBTZEUR", name, canonical)
+		}
+	}
+
+	canonicals, aliases, err := registry.ResolvePairs([]string{"BTC/EUR", "DASH/EUR", "XBTEUR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if canonicals[0] != "XXBTZEUR" || canonicals[1] != "DASHEUR" || canonicals[2] != "XXBTZEUR" {
+		t.Errorf("Unexpected canonical names: %v", canonicals)
+	}
+
+	if names := registry.aliasesFor(aliases, "XBTEUR"); len(names) != 2 || names[0] != "BTC/EUR" || names[1] != "XBTEUR" {
+		t.Errorf("Unexpected aliases for XBTEUR: %v", names)
+	}
+}
+
+func TestRegistryResolvePairPreference(t *testing.T) {
+	assets := map[string]Asset{
+		"XXBT": {Altname: "XBT"},
+		"ZEUR": {Altname: "EUR"},
+	}
+
+	// Pairs sharing base & quote: the one named after them is preferred, whatever the map order
+	pairs := map[string]AssetPair{
+		"AXBTEUR":    {Altname: "AXBTEUR", Base: "XXBT", Quote: "ZEUR"},
+		"XXBTZEUR":   {Altname: "XBTEUR", Wsname: "XBT/EUR", Base: "XXBT", Quote: "ZEUR"},
+		"XXBTZEUR.d": {Altname: "XBTEUR.d", Base: "XXBT", Quote: "ZEUR"},
+		"ZXBTEUR":    {Altname: "ZXBTEUR", Base: "XXBT", Quote: "ZEUR"},
+	}
+
+	for i := 0; i < 20; i++ {
+		registry := NewRegistry(nil, 0)
+		registry.load(assets, pairs)
+
+		for _, name := range []string{"BTC/EUR", "XBT_ZEUR", "btc-eur"} {
+			canonical, err := registry.ResolvePair(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if canonical != "XXBTZEUR" {
+				t.Fatalf("%s resolved to %s instead of XXBTZEUR", name, canonical)
+			}
+		}
+	}
+}
+
+func TestRegistrySymbolV2(t *testing.T) {
+	registry := createTestRegistry()
+
+	for name, expected := range map[string]string{"XXBTZEUR": "BTC/EUR", "XBTEUR": "BTC/EUR", "BTC/EUR": "BTC/EUR", "DASHEUR": "DASH/EUR"} {
+		symbol, err := registry.SymbolV2(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if symbol != expected {
+			t.Errorf("%s: unexpected symbol %s instead of %s", name, symbol, expected)
+		}
+	}
+
+	if _, err := registry.SymbolV2("XXBTZEUR.d"); err == nil {
+		t.Error("Pairs without WebSocket name have no v2 symbol")
+	}
+}
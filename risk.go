@@ -0,0 +1,303 @@
+package krakenapi
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Names of the pre-trade rules, as found in RiskError.Rule
+const (
+	RISK_PAIR_NOT_ALLOWED = "pair_not_allowed"
+	RISK_ORDER_NOTIONAL   = "order_notional"
+	RISK_PAIR_NOTIONAL    = "pair_notional"
+	RISK_POSITION         = "position"
+	RISK_FAT_FINGER       = "fat_finger"
+)
+
+// Returned by RiskChecker when an order breaks a limit. The order is not sent.
+type RiskError struct {
+	Rule  string  // broken rule (RISK_*)
+	Pair  string  // pair of the order
+	Value float64 // value of the order for the rule (notional, position, price deviation)
+	Limit float64 // configured limit
+}
+
+func (e *RiskError) Error() string {
+	return fmt.Sprintf("Order rejected by pre-trade check %s on %s (%f, limit %f)", e.Rule, e.Pair, e.Value, e.Limit)
+}
+
+// Pre-trade limits. Zero values disable the corresponding check.
+type RiskLimits struct {
+	AllowedPairs     []string           // pairs that can be traded, any alias; empty allows all pairs
+	MaxOrderNotional float64            // maximum price * volume of an order (quote currency)
+	MaxPairNotional  map[string]float64 // maximum notional of open orders and the new order, per pair (any alias)
+	MaxPosition      map[string]float64 // maximum absolute position per asset (any alias): balance, open margin positions and the new order
+	FatFingerBand    float64            // maximum relative distance of limit and trigger prices to the ticker mid (ie: 0.05 for 5%)
+}
+
+// RiskChecker checks orders against RiskLimits. Set KrakenApi.PreTradeCheck to
+// its Check method, and KrakenApi.PreTradeBatchCheck to CheckBatch, to check all
+// orders before they are sent.
+type RiskChecker struct {
+	api      *KrakenApi
+	registry *Registry
+	Limits   RiskLimits
+}
+
+// Create a risk checker. The registry resolves pair & asset names.
+func (api *KrakenApi) NewRiskChecker(registry *Registry, limits RiskLimits) *RiskChecker {
+	return &RiskChecker{
+		api:      api,
+		registry: registry,
+		Limits:   limits,
+	}
+}
+
+// Check an order against the limits. Returns a *RiskError if a limit is broken.
+func (c *RiskChecker) Check(order *OrderRequest) error {
+	return c.CheckBatch([]*OrderRequest{order})
+}
+
+/*
+Check orders sent together (ie: by AddOrderBatch) against the limits. Exposure
+(open orders, balances, positions, tickers) is loaded once, and each order is
+checked with the previous orders of the batch added to it. Returns a *RiskError
+if a limit is broken. Set KrakenApi.PreTradeBatchCheck to this method.
+*/
+func (c *RiskChecker) CheckBatch(orders []*OrderRequest) error {
+	exposure := &riskExposure{checker: c}
+
+	for _, order := range orders {
+		err := c.check(order, exposure)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *RiskChecker) check(order *OrderRequest, exposure *riskExposure) error {
+	pair, err := c.registry.Pair(order.Pair)
+	if err != nil {
+		return err
+	}
+
+	pair_name, err := c.registry.ResolvePair(order.Pair)
+	if err != nil {
+		return err
+	}
+
+	err = c.checkAllowed(pair_name)
+	if err != nil {
+		return err
+	}
+
+	price := order.Price
+
+	if c.Limits.FatFingerBand != 0 || order.OrderType == "market" {
+		mid, err := exposure.mid(pair_name)
+		if err != nil {
+			return err
+		}
+
+		if order.OrderType == "market" {
+			price = mid
+		}
+
+		// Limit and trigger prices, but not trailing offsets
+		if c.Limits.FatFingerBand != 0 && order.OrderType != "market" && !strings.HasPrefix(order.OrderType, "trailing-stop") {
+			for _, p := range []float64{order.Price, order.Price2} {
+				if p == 0 {
+					continue
+				}
+
+				deviation := math.Abs(p-mid) / mid
+				if deviation > c.Limits.FatFingerBand {
+					return &RiskError{RISK_FAT_FINGER, order.Pair, deviation, c.Limits.FatFingerBand}
+				}
+			}
+		}
+	}
+
+	notional := price * order.Volume
+
+	if c.Limits.MaxOrderNotional != 0 && notional > c.Limits.MaxOrderNotional {
+		return &RiskError{RISK_ORDER_NOTIONAL, order.Pair, notional, c.Limits.MaxOrderNotional}
+	}
+
+	err = c.checkPairNotional(order, pair_name, notional, exposure)
+	if err != nil {
+		return err
+	}
+
+	return c.checkPosition(order, pair, exposure)
+}
+
+func (c *RiskChecker) checkAllowed(pair_name string) error {
+	if len(c.Limits.AllowedPairs) == 0 {
+		return nil
+	}
+
+	for _, allowed := range c.Limits.AllowedPairs {
+		name, err := c.registry.ResolvePair(allowed)
+		if err == nil && name == pair_name {
+			return nil
+		}
+	}
+
+	return &RiskError{Rule: RISK_PAIR_NOT_ALLOWED, Pair: pair_name}
+}
+
+// Returns the limit configured for name in limits, any alias being accepted as key.
+func (c *RiskChecker) limitFor(limits map[string]float64, name string, resolve func(string) (string, error)) (float64, bool) {
+	for key, limit := range limits {
+		resolved, err := resolve(key)
+		if err == nil && resolved == name {
+			return limit, true
+		}
+	}
+
+	return 0, false
+}
+
+func (c *RiskChecker) checkPairNotional(order *OrderRequest, pair_name string, notional float64, exposure *riskExposure) error {
+	limit, ok := c.limitFor(c.Limits.MaxPairNotional, pair_name, c.registry.ResolvePair)
+	if !ok {
+		return nil
+	}
+
+	open, err := exposure.pairNotional(pair_name)
+	if err != nil {
+		return err
+	}
+
+	notional += open
+
+	if notional > limit {
+		return &RiskError{RISK_PAIR_NOTIONAL, order.Pair, notional, limit}
+	}
+
+	exposure.notionals[pair_name] = notional
+
+	return nil
+}
+
+func (c *RiskChecker) checkPosition(order *OrderRequest, pair AssetPair, exposure *riskExposure) error {
+	limit, ok := c.limitFor(c.Limits.MaxPosition, pair.Base, c.registry.ResolveAsset)
+	if !ok {
+		return nil
+	}
+
+	position, err := exposure.position(pair.Base)
+	if err != nil {
+		return err
+	}
+
+	if order.Type == "buy" {
+		position += order.Volume
+	} else {
+		position -= order.Volume
+	}
+
+	if math.Abs(position) > limit {
+		return &RiskError{RISK_POSITION, order.Pair, position, limit}
+	}
+
+	exposure.positions[pair.Base] = position
+
+	return nil
+}
+
+// Exposure of the account, loaded at most once per CheckBatch, with the orders checked so far added.
+type riskExposure struct {
+	checker   *RiskChecker
+	mids      map[string]float64 // ticker mid, by canonical pair name
+	notionals map[string]float64 // open orders notional, by canonical pair name
+	positions map[string]float64 // balance and open margin positions, by canonical asset name
+}
+
+func (e *riskExposure) mid(pair_name string) (float64, error) {
+	if mid, ok := e.mids[pair_name]; ok {
+		return mid, nil
+	}
+
+	tickers, err := e.checker.api.ApiTicker([]string{pair_name})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, ticker := range tickers {
+		if e.mids == nil {
+			e.mids = make(map[string]float64)
+		}
+
+		e.mids[pair_name] = (ticker.Ask.Price + ticker.Bid.Price) / 2
+
+		return e.mids[pair_name], nil
+	}
+
+	return 0, fmt.Errorf("No ticker for %s", pair_name)
+}
+
+func (e *riskExposure) pairNotional(pair_name string) (float64, error) {
+	if e.notionals == nil {
+		open, err := e.checker.api.ApiOpenOrders(false, "")
+		if err != nil {
+			return 0, err
+		}
+
+		e.notionals = make(map[string]float64)
+
+		for _, o := range open.Open {
+			name, err := e.checker.registry.ResolvePair(o.Descr.Pair)
+			if err != nil {
+				continue
+			}
+
+			e.notionals[name] += o.Descr.Price * (o.Vol - o.VolExec)
+		}
+	}
+
+	return e.notionals[pair_name], nil
+}
+
+func (e *riskExposure) position(asset string) (float64, error) {
+	if e.positions == nil {
+		balances, err := e.checker.api.ApiBalance()
+		if err != nil {
+			return 0, err
+		}
+
+		positions, err := e.checker.api.ApiOpenPositions("", false)
+		if err != nil {
+			return 0, err
+		}
+
+		e.positions = make(map[string]float64)
+		for name, balance := range balances {
+			e.positions[name] = balance
+		}
+
+		for _, p := range positions {
+			name, err := e.checker.registry.ResolvePair(p.Pair)
+			if err != nil {
+				continue
+			}
+
+			pair, err := e.checker.registry.Pair(name)
+			if err != nil {
+				continue
+			}
+
+			if p.Type == "buy" {
+				e.positions[pair.Base] += p.Vol - p.VolClosed
+			} else {
+				e.positions[pair.Base] -= p.Vol - p.VolClosed
+			}
+		}
+	}
+
+	return e.positions[asset], nil
+}
@@ -0,0 +1,149 @@
+package krakenapi
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRiskChecker(t *testing.T) {
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case URL_PUBLIC_TICKER:
+			w.Write([]byte(`{"error":[],"result":{"XXBTZEUR":{"a":["101","1","1.0"],"b":["99","1","1.0"],"c":["100","1"],"v":["1","1"],"p":["1","1"],"t":[1,1],"l":["1","1"],"h":["1","1"],"o":"100"}}}`))
+		case URL_PRIVATE_OPEN_ORDERS:
+			w.Write([]byte(`{"error":[],"result":{"open":{"OTXID":{"status":"open","vol":"2","vol_exec":"1","descr":{"pair":"XBTEUR","price":"90"}}}}}`))
+		case URL_PRIVATE_QUERY_ORDERS:
+			w.Write([]byte(`{"error":[],"result":{"OTXID":{"status":"open","vol":"2","vol_exec":"1","descr":{"pair":"XBTEUR","type":"buy","ordertype":"limit","price":"90"}}}}`))
+		case URL_PRIVATE_EDIT_ORDER, URL_PRIVATE_AMEND_ORDER:
+			t.Errorf("Order change %s should have been refused", r.URL.Path)
+		case URL_PRIVATE_BALANCE:
+			w.Write([]byte(`{"error":[],"result":{"XXBT":"1.5","ZEUR":"1000"}}`))
+		case URL_PRIVATE_OPEN_POSITIONS:
+			w.Write([]byte(`{"error":[],"result":{"TPOS":{"pair":"XXBTZEUR","type":"buy","vol":"1","vol_closed":"0.5"}}}`))
+		}
+	})
+	defer server.Close()
+
+	registry := createTestRegistry()
+	registry.api = client
+
+	checker := client.NewRiskChecker(registry, RiskLimits{
+		AllowedPairs:     []string{"BTC/EUR"},
+		MaxOrderNotional: 480,
+		MaxPairNotional:  map[string]float64{"BTC/EUR": 500},
+		MaxPosition:      map[string]float64{"BTC": 3},
+		FatFingerBand:    0.05,
+	})
+	client.PreTradeCheck = checker.Check
+
+	tests := []struct {
+		order OrderRequest
+		rule  string
+	}{
+		{OrderRequest{Pair: "BTC/EUR", Type: "buy", OrderType: "limit", Price: 100, Volume: 1}, ""},
+		{OrderRequest{Pair: "DASH/EUR", Type: "buy", OrderType: "limit", Price: 100, Volume: 1}, RISK_PAIR_NOT_ALLOWED},
+		{OrderRequest{Pair: "XBTEUR", Type: "buy", OrderType: "limit", Price: 120, Volume: 1}, RISK_FAT_FINGER},
+		{OrderRequest{Pair: "XBTEUR", Type: "buy", OrderType: "stop-loss-limit", Price: 102, Price2: 120, Volume: 1}, RISK_FAT_FINGER},
+		{OrderRequest{Pair: "XBTEUR", Type: "sell", OrderType: "stop-loss", Price: 80, Volume: 1}, RISK_FAT_FINGER},
+		{OrderRequest{Pair: "XBTEUR", Type: "sell", OrderType: "trailing-stop", Price: 2, Volume: 1}, ""},
+		{OrderRequest{Pair: "XBTEUR", Type: "sell", OrderType: "market", Volume: 6}, RISK_ORDER_NOTIONAL},
+		{OrderRequest{Pair: "XBTEUR", Type: "sell", OrderType: "limit", Price: 100, Volume: 4.5}, RISK_PAIR_NOTIONAL},
+		{OrderRequest{Pair: "XBTEUR", Type: "buy", OrderType: "limit", Price: 100, Volume: 1.5}, RISK_POSITION},
+	}
+
+	for _, test := range tests {
+		err := checker.Check(&test.order)
+
+		if test.rule == "" {
+			if err != nil {
+				t.Errorf("%v: unexpected error %s", test.order, err)
+			}
+			continue
+		}
+
+		risk_err, ok := err.(*RiskError)
+		if !ok || risk_err.Rule != test.rule {
+			t.Errorf("%v: expected %s, got %v", test.order, test.rule, err)
+		}
+	}
+
+	_, err := client.ApiAddOrder("XBTEUR", "buy", "limit", 200, 0, 1, "")
+	if _, ok := err.(*RiskError); !ok {
+		t.Errorf("Expected AddOrder to be refused, got %v", err)
+	}
+
+	_, err = client.ApiEditOrder("OTXID", &OrderRequest{Pair: "XBTEUR", Price: 200})
+	if _, ok := err.(*RiskError); !ok {
+		t.Errorf("Expected EditOrder to be refused, got %v", err)
+	}
+
+	// 5 left to execute at 100: over the order notional
+	_, err = client.ApiAmendOrder("OTXID", &OrderRequest{Price: 100, Volume: 6})
+	if risk_err, ok := err.(*RiskError); !ok || risk_err.Rule != RISK_ORDER_NOTIONAL {
+		t.Errorf("Expected AmendOrder to be refused, got %v", err)
+	}
+}
+
+func TestRiskCheckerBatch(t *testing.T) {
+	calls := make(map[string]int)
+
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		calls[r.URL.Path]++
+
+		switch r.URL.Path {
+		case URL_PUBLIC_TICKER:
+			w.Write([]byte(`{"error":[],"result":{"XXBTZEUR":{"a":["101","1","1.0"],"b":["99","1","1.0"],"c":["100","1"],"v":["1","1"],"p":["1","1"],"t":[1,1],"l":["1","1"],"h":["1","1"],"o":"100"}}}`))
+		case URL_PRIVATE_OPEN_ORDERS:
+			w.Write([]byte(`{"error":[],"result":{"open":{"OTXID":{"status":"open","vol":"2","vol_exec":"1","descr":{"pair":"XBTEUR","price":"90"}}}}}`))
+		case URL_PRIVATE_BALANCE:
+			w.Write([]byte(`{"error":[],"result":{"XXBT":"1.5","ZEUR":"1000"}}`))
+		case URL_PRIVATE_OPEN_POSITIONS:
+			w.Write([]byte(`{"error":[],"result":{"TPOS":{"pair":"XXBTZEUR","type":"buy","vol":"1","vol_closed":"0.5"}}}`))
+		default:
+			t.Errorf("Unexpected request %s", r.URL.Path)
+		}
+	})
+	defer server.Close()
+
+	registry := createTestRegistry()
+	registry.api = client
+
+	checker := client.NewRiskChecker(registry, RiskLimits{
+		MaxPairNotional: map[string]float64{"BTC/EUR": 500},
+		MaxPosition:     map[string]float64{"BTC": 3},
+		FatFingerBand:   0.05,
+	})
+	client.PreTradeCheck = checker.Check
+	client.PreTradeBatchCheck = checker.CheckBatch
+
+	// Each order fits the 3 BTC position limit alone (2 + 0.6), not together (2 + 1.2)
+	orders := []*OrderRequest{
+		{Type: "buy", OrderType: "limit", Price: 100, Volume: 0.6},
+		{Type: "buy", OrderType: "limit", Price: 100, Volume: 0.6},
+	}
+
+	_, err := client.ApiAddOrderBatch("XXBTZEUR", orders, false)
+	if risk_err, ok := err.(*RiskError); !ok || risk_err.Rule != RISK_POSITION {
+		t.Errorf("Expected the batch to break the position limit, got %v", err)
+	}
+
+	// Exposure is loaded once for the whole batch
+	for path, count := range calls {
+		if count != 1 {
+			t.Errorf("%s called %d times", path, count)
+		}
+	}
+
+	// 90 open, then 4 * 110 over the 500 pair notional
+	orders = []*OrderRequest{
+		{Pair: "XBTEUR", Type: "sell", OrderType: "limit", Price: 100, Volume: 1.1},
+		{Pair: "XBTEUR", Type: "buy", OrderType: "limit", Price: 100, Volume: 1.1},
+		{Pair: "XBTEUR", Type: "sell", OrderType: "limit", Price: 100, Volume: 1.1},
+		{Pair: "XBTEUR", Type: "buy", OrderType: "limit", Price: 100, Volume: 1.1},
+	}
+
+	err = checker.CheckBatch(orders)
+	if risk_err, ok := err.(*RiskError); !ok || risk_err.Rule != RISK_PAIR_NOTIONAL {
+		t.Errorf("Expected the batch to break the pair notional limit, got %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package krakenapi
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type SpreadUpdate struct {
+	Pair   string // pair as given to Add
+	Spread Spread
+}
+
+type spreadState struct {
+	since  string
+	window []Spread // ordered by time
+}
+
+/*
+SpreadPoller polls the Spread endpoint of pairs, and keeps a rolling window of
+their spreads.
+
+Since is inclusive: the spreads returned at the time of the previous poll's last
+spreads replace them. Only spreads which were not known yet are reported as
+updates, so that each spread is reported once.
+*/
+type SpreadPoller struct {
+	api      *KrakenApi
+	Interval time.Duration     // delay between polls
+	Window   time.Duration     // spreads older than the pair's latest spread by more than Window are dropped
+	Updates  chan SpreadUpdate // updates, when started
+	OnError  func(error)       // called from the goroutine when a poll fails (optional)
+
+	mutex sync.Mutex
+	pairs map[string]*spreadState
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// Create a spread poller polling every interval. Updates are buffered up to buffer updates;
+// when the buffer is full, polling waits for updates to be read.
+func (api *KrakenApi) NewSpreadPoller(interval, window time.Duration, buffer int) *SpreadPoller {
+	return &SpreadPoller{
+		api:      api,
+		Interval: interval,
+		Window:   window,
+		Updates:  make(chan SpreadUpdate, buffer),
+		pairs:    make(map[string]*spreadState),
+	}
+}
+
+// Start polling the given pairs.
+func (p *SpreadPoller) Add(pairs ...string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, pair := range pairs {
+		if _, ok := p.pairs[pair]; !ok {
+			p.pairs[pair] = &spreadState{}
+		}
+	}
+}
+
+// Stop polling the given pairs and drop their windows.
+func (p *SpreadPoller) Remove(pairs ...string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, pair := range pairs {
+		delete(p.pairs, pair)
+	}
+}
+
+// Returns the spreads of the pair's rolling window, oldest first.
+func (p *SpreadPoller) Spreads(pair string) []Spread {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	state, ok := p.pairs[pair]
+	if !ok {
+		return nil
+	}
+
+	spreads := make([]Spread, len(state.window))
+	copy(spreads, state.window)
+
+	return spreads
+}
+
+/*
+Poll all pairs once, returning the new spreads.
+When the poller is started, updates are sent on Updates instead.
+*/
+func (p *SpreadPoller) Poll() ([]SpreadUpdate, error) {
+	p.mutex.Lock()
+	pairs := make(map[string]string)
+	for pair, state := range p.pairs {
+		pairs[pair] = state.since
+	}
+	p.mutex.Unlock()
+
+	updates := []SpreadUpdate{}
+
+	for pair, since := range pairs {
+		result, last, err := p.api.ApiSpread(pair, since)
+		if err != nil {
+			return updates, err
+		}
+
+		spreads := []Spread{}
+		for _, pair_spreads := range result {
+			spreads = append(spreads, pair_spreads...)
+		}
+
+		p.mutex.Lock()
+		state, ok := p.pairs[pair]
+		if ok {
+			for _, spread := range state.apply(spreads, p.Window) {
+				updates = append(updates, SpreadUpdate{pair, spread})
+			}
+
+			if last != 0 {
+				state.since = strconv.FormatFloat(last, 'f', -1, 64)
+			}
+		}
+		p.mutex.Unlock()
+	}
+
+	return updates, nil
+}
+
+// Apply a page of spreads to the window, returning the spreads not known yet.
+func (s *spreadState) apply(spreads []Spread, window time.Duration) []Spread {
+	received := make(map[float64][]Spread)
+	for _, spread := range spreads {
+		received[spread.Time] = append(received[spread.Time], spread)
+	}
+
+	fresh := []Spread{}
+	kept := []Spread{}
+
+	// Spreads at a received time are replaced by the received ones.
+	known := make(map[float64]map[Spread]int)
+	for _, spread := range s.window {
+		if _, ok := received[spread.Time]; !ok {
+			kept = append(kept, spread)
+			continue
+		}
+
+		if known[spread.Time] == nil {
+			known[spread.Time] = make(map[Spread]int)
+		}
+		known[spread.Time][spread]++
+	}
+
+	for _, spread := range spreads {
+		if known[spread.Time][spread] > 0 {
+			known[spread.Time][spread]--
+		} else {
+			fresh = append(fresh, spread)
+		}
+	}
+
+	s.window = append(kept, spreads...)
+	sort.SliceStable(s.window, func(i, j int) bool { return s.window[i].Time < s.window[j].Time })
+
+	if window > 0 && len(s.window) > 0 {
+		oldest := s.window[len(s.window)-1].Time - window.Seconds()
+
+		start := 0
+		for start < len(s.window) && s.window[start].Time < oldest {
+			start++
+		}
+
+		s.window = s.window[start:]
+	}
+
+	return fresh
+}
+
+// Start polling from a goroutine.
+func (p *SpreadPoller) Start() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.stop != nil {
+		return fmt.Errorf("Spread poller already started")
+	}
+
+	if p.Interval <= 0 {
+		return fmt.Errorf("Invalid spread poller interval %s", p.Interval)
+	}
+
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+
+	go p.run(p.stop, p.done)
+
+	return nil
+}
+
+func (p *SpreadPoller) run(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			updates, err := p.Poll()
+			if err != nil && p.OnError != nil {
+				p.OnError(err)
+			}
+
+			for _, update := range updates {
+				select {
+				case p.Updates <- update:
+				case <-stop:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Stop polling, waiting for the goroutine to exit.
+func (p *SpreadPoller) Stop() {
+	p.mutex.Lock()
+	stop, done := p.stop, p.done
+	p.mutex.Unlock()
+
+	if stop == nil {
+		return
+	}
+
+	close(stop)
+	<-done
+
+	p.mutex.Lock()
+	p.stop = nil
+	p.done = nil
+	p.mutex.Unlock()
+}
@@ -0,0 +1,64 @@
+package krakenapi
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSpreadPoller(t *testing.T) {
+	pages := map[string]string{
+		"":    `{"error":[],"result":{"XXBTZEUR":[[100,"1.0","2.0"],[101,"1.1","2.1"],[101,"1.2","2.2"]],"last":101}}`,
+		"101": `{"error":[],"result":{"XXBTZEUR":[[101,"1.2","2.2"],[101,"1.3","2.3"],[103,"1.4","2.4"]],"last":103}}`,
+	}
+
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.FormValue("since")]
+		if !ok {
+			t.Errorf("Unexpected since %s", r.FormValue("since"))
+		}
+
+		w.Write([]byte(page))
+	})
+	defer server.Close()
+
+	poller := client.NewSpreadPoller(time.Second, 2*time.Second, 10)
+	poller.Add("XBTEUR")
+
+	updates, err := poller.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(updates) != 3 || updates[0].Pair != "XBTEUR" || updates[0].Spread.Bid != 1 || updates[0].Spread.Ask != 2 {
+		t.Errorf("Unexpected first updates %v", updates)
+	}
+
+	updates, err = poller.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1.1 at 101 is replaced, 1.2 at 101 is known
+	if len(updates) != 2 || updates[0].Spread.Bid != 1.3 || updates[1].Spread.Time != 103 {
+		t.Errorf("Unexpected new updates %v", updates)
+	}
+
+	err = client.NewSpreadPoller(0, time.Second, 10).Start()
+	if err == nil {
+		t.Error("A zero interval should be refused")
+	}
+
+	spreads := poller.Spreads("XBTEUR")
+	expected := []Spread{{101, 1.2, 2.2}, {101, 1.3, 2.3}, {103, 1.4, 2.4}}
+
+	if len(spreads) != len(expected) {
+		t.Fatalf("Unexpected window %v", spreads)
+	}
+
+	for i := range expected {
+		if spreads[i] != expected[i] {
+			t.Errorf("Unexpected window %v", spreads)
+		}
+	}
+}
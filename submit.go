@@ -0,0 +1,179 @@
+package krakenapi
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Number of attempts and delay between attempts when resolving the outcome of an order.
+var (
+	SubmitRecoveryAttempts = 3
+	SubmitRecoveryDelay    = 2 * time.Second
+)
+
+// Returned by SubmitOrder when the order is known not to have been placed.
+type OrderNotPlacedError struct {
+	ClOrdId string
+	Userref string
+	Err     error // error returned by AddOrder
+}
+
+func (e *OrderNotPlacedError) Error() string {
+	return fmt.Sprintf("Order not placed (%s)", e.Err)
+}
+
+// Returned by SubmitOrder when it could not determine whether the order was placed.
+type OrderOutcomeUnknownError struct {
+	ClOrdId string
+	Userref string
+	Err     error // last error met while resolving the outcome
+}
+
+func (e *OrderOutcomeUnknownError) Error() string {
+	return fmt.Sprintf("Unknown order outcome (%s)", e.Err)
+}
+
+// Returns a random (version 4) UUID, usable as a client order id.
+func NewClOrdId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+/*
+Submit an order, making sure its outcome is known.
+
+The order is tagged with a client order id, generated if neither ClOrdId nor
+Userref are set. If AddOrder fails without a response from Kraken (timeout,
+network error...) or with a service error (EService:Unavailable, EService:Busy,
+EGeneral:Internal error), open and closed orders are searched for that tag.
+
+Returns the order result if the order exists, an *OrderNotPlacedError if none of
+the SubmitRecoveryAttempts searches found it, or an *OrderOutcomeUnknownError if
+the outcome could not be resolved.
+Errors from OrderRequest.Check and PreTradeCheck are returned as is, the order
+not being sent.
+*/
+func (api *KrakenApi) SubmitOrder(order *OrderRequest) (*OrderResult, error) {
+	if order.ClOrdId == "" && order.Userref == "" {
+		order.ClOrdId = NewClOrdId()
+	}
+
+	err := order.Check()
+	if err != nil {
+		return nil, err
+	}
+
+	if api.PreTradeCheck != nil {
+		err = api.PreTradeCheck(order)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	submitted := time.Now()
+
+	result, err := api.addOrder(order)
+	if err == nil {
+		return result, nil
+	}
+
+	switch e := err.(type) {
+	case *ApiError:
+		if !e.Unavailable() {
+			return nil, &OrderNotPlacedError{order.ClOrdId, order.Userref, err}
+		}
+	case *ModeError, *KillSwitchError:
+		return nil, &OrderNotPlacedError{order.ClOrdId, order.Userref, err}
+	}
+
+	submit_err := err
+	misses := 0
+
+	// Kraken may still be processing the order: it is only considered not placed
+	// once every attempt succeeded without finding it.
+	for attempt := 0; attempt < SubmitRecoveryAttempts; attempt++ {
+		time.Sleep(SubmitRecoveryDelay)
+
+		var txid string
+		var found bool
+
+		txid, found, err = api.findSubmittedOrder(order, submitted)
+		if err != nil {
+			continue
+		}
+
+		if !found {
+			misses++
+			err = fmt.Errorf("Order not found (%s)", submit_err)
+			continue
+		}
+
+		result := &OrderResult{Txid: []string{txid}}
+
+		return result, nil
+	}
+
+	if misses > 0 && misses == SubmitRecoveryAttempts {
+		return nil, &OrderNotPlacedError{order.ClOrdId, order.Userref, submit_err}
+	}
+
+	return nil, &OrderOutcomeUnknownError{order.ClOrdId, order.Userref, err}
+}
+
+// Search open & closed orders for an order matching the tag of the given order.
+func (api *KrakenApi) findSubmittedOrder(order *OrderRequest, submitted time.Time) (string, bool, error) {
+	params := url.Values{}
+	if order.ClOrdId != "" {
+		params.Set("cl_ord_id", order.ClOrdId)
+	} else {
+		params.Set("userref", order.Userref)
+	}
+
+	open, err := api.openOrders(params)
+	if err != nil {
+		return "", false, err
+	}
+
+	for txid, o := range open.Open {
+		if matchSubmittedOrder(order, &o, submitted) {
+			return txid, true, nil
+		}
+	}
+
+	// Allow for some clock skew between us and Kraken.
+	params.Set("start", strconv.FormatInt(submitted.Add(-time.Minute).Unix(), 10))
+
+	closed, err := api.closedOrders(params)
+	if err != nil {
+		return "", false, err
+	}
+
+	for txid, o := range closed.Closed {
+		if matchSubmittedOrder(order, &o, submitted) {
+			return txid, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+func matchSubmittedOrder(order *OrderRequest, o *Order, submitted time.Time) bool {
+	if order.ClOrdId != "" {
+		return o.ClOrdId == order.ClOrdId
+	}
+
+	// userrefs are not unique: also match the order itself.
+	return o.Userref == order.Userref &&
+		o.Descr.Type == order.Type &&
+		o.Descr.Ordertype == order.OrderType &&
+		o.Vol == order.Volume &&
+		o.Opentm >= float64(submitted.Add(-time.Minute).Unix())
+}
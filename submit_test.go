@@ -0,0 +1,148 @@
+package krakenapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Returns a client querying a local server answering with the given handler.
+func createTestApiClient(handler http.HandlerFunc) (*KrakenApi, *httptest.Server) {
+	server := httptest.NewServer(handler)
+
+	client := New("", "")
+	client.ApiRoot = server.URL
+
+	return client, server
+}
+
+func TestOrderUserrefDecoding(t *testing.T) {
+	var orders OpenOrders
+
+	_, err := parse([]byte(`{"error":[],"result":{"open":{"OABC":{"userref":42,"cl_ord_id":"id","status":"open"},"ODEF":{"userref":null}}}}`), &orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if orders.Open["OABC"].Userref != "42" || orders.Open["OABC"].ClOrdId != "id" {
+		t.Errorf("Unexpected order: %v", orders.Open["OABC"])
+	}
+
+	if orders.Open["ODEF"].Userref != "" {
+		t.Errorf("Unexpected userref: %s", orders.Open["ODEF"].Userref)
+	}
+}
+
+func TestSubmitOrderRecovery(t *testing.T) {
+	delay := SubmitRecoveryDelay
+	SubmitRecoveryDelay = time.Millisecond
+	defer func() { SubmitRecoveryDelay = delay }()
+
+	var cl_ord_id string
+	placed := true
+	lookups := 0
+
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		switch r.URL.Path {
+		case URL_PRIVATE_ADD_ORDER:
+			// Simulate a lost response
+			cl_ord_id = r.Form.Get("cl_ord_id")
+			hj, _ := w.(http.Hijacker)
+			conn, _, _ := hj.Hijack()
+			conn.Close()
+		case URL_PRIVATE_OPEN_ORDERS:
+			lookups++
+			if lookups == 1 && !placed {
+				// Lookups failing then missing the order do not prove it was not placed
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+
+			if placed && r.Form.Get("cl_ord_id") == cl_ord_id {
+				w.Write([]byte(`{"error":[],"result":{"open":{"OTXID":{"cl_ord_id":"` + cl_ord_id + `","status":"open"}}}}`))
+			} else {
+				w.Write([]byte(`{"error":[],"result":{"open":{}}}`))
+			}
+		case URL_PRIVATE_CLOSED_ORDERS:
+			w.Write([]byte(`{"error":[],"result":{"closed":{},"count":0}}`))
+		}
+	})
+	defer server.Close()
+
+	order := &OrderRequest{Pair: "XXBTZEUR", Type: "buy", OrderType: "limit", Price: 1, Volume: 0.1}
+
+	result, err := client.SubmitOrder(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if order.ClOrdId == "" || order.ClOrdId != cl_ord_id {
+		t.Errorf("Unexpected client order id %s (sent %s)", order.ClOrdId, cl_ord_id)
+	}
+
+	if len(result.Txid) != 1 || result.Txid[0] != "OTXID" {
+		t.Errorf("Unexpected result: %v", result)
+	}
+
+	placed = false
+	lookups = 0
+
+	_, err = client.SubmitOrder(&OrderRequest{Pair: "XXBTZEUR", Type: "buy", OrderType: "limit", Price: 1, Volume: 0.1})
+	if _, ok := err.(*OrderOutcomeUnknownError); !ok {
+		t.Errorf("Expected an OrderOutcomeUnknownError, got %v", err)
+	}
+
+	_, err = client.SubmitOrder(&OrderRequest{Pair: "XXBTZEUR", Type: "buy", OrderType: "limit", Price: 1, Volume: 0.1})
+	if _, ok := err.(*OrderNotPlacedError); !ok {
+		t.Errorf("Expected an OrderNotPlacedError, got %v", err)
+	}
+
+	if lookups != 2*SubmitRecoveryAttempts {
+		t.Errorf("Unexpected number of lookups %d", lookups)
+	}
+}
+
+func TestSubmitOrderServiceError(t *testing.T) {
+	delay := SubmitRecoveryDelay
+	SubmitRecoveryDelay = time.Millisecond
+	defer func() { SubmitRecoveryDelay = delay }()
+
+	add_error := ""
+	lookups := 0
+
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		switch r.URL.Path {
+		case URL_PRIVATE_ADD_ORDER:
+			w.Write([]byte(`{"error":["` + add_error + `"]}`))
+		case URL_PRIVATE_OPEN_ORDERS:
+			// The order was accepted despite the error
+			lookups++
+			w.Write([]byte(`{"error":[],"result":{"open":{"OTXID":{"cl_ord_id":"` + r.Form.Get("cl_ord_id") + `","status":"open"}}}}`))
+		}
+	})
+	defer server.Close()
+
+	add_error = "EService:Unavailable"
+
+	result, err := client.SubmitOrder(&OrderRequest{Pair: "XXBTZEUR", Type: "buy", OrderType: "limit", Price: 1, Volume: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Txid) != 1 || result.Txid[0] != "OTXID" || lookups != 1 {
+		t.Errorf("Unexpected result: %v (%d lookups)", result, lookups)
+	}
+
+	add_error = "EOrder:Insufficient funds"
+	lookups = 0
+
+	_, err = client.SubmitOrder(&OrderRequest{Pair: "XXBTZEUR", Type: "buy", OrderType: "limit", Price: 1, Volume: 0.1})
+	if _, ok := err.(*OrderNotPlacedError); !ok || lookups != 0 {
+		t.Errorf("Expected an OrderNotPlacedError without lookup, got %v (%d lookups)", err, lookups)
+	}
+}
@@ -0,0 +1,292 @@
+package krakenapi
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Maximum number of txids QueryOrders accepts at once.
+const QUERY_ORDERS_MAX_TXIDS = 20
+
+// Default number of polls an order may be missing from QueryOrders before being untracked.
+var OrderTrackerMaxMisses = 5
+
+type OrderEventType int
+
+const (
+	OrderAcknowledged    OrderEventType = iota // order is open in the book
+	OrderPartiallyFilled                       // executed volume increased, order still open
+	OrderFilled                                // order is closed
+	OrderCanceled                              // order was canceled, possibly after partial fills
+	OrderExpired                               // order expired, possibly after partial fills
+	OrderUnknown                               // order not returned by QueryOrders for MaxMisses polls, untracked
+)
+
+func (t OrderEventType) String() string {
+	switch t {
+	case OrderAcknowledged:
+		return "acknowledged"
+	case OrderPartiallyFilled:
+		return "partially filled"
+	case OrderFilled:
+		return "filled"
+	case OrderCanceled:
+		return "canceled"
+	case OrderExpired:
+		return "expired"
+	case OrderUnknown:
+		return "unknown"
+	}
+
+	return fmt.Sprintf("OrderEventType(%d)", int(t))
+}
+
+type OrderEvent struct {
+	Type  OrderEventType
+	Txid  string
+	Order Order // order state when the event was detected
+}
+
+type trackedOrder struct {
+	acknowledged bool
+	volExec      float64
+	misses       int // consecutive polls without the order
+}
+
+// OrderTracker follows orders through their lifecycle by polling QueryOrders,
+// and sends an OrderEvent on Events for each change.
+// Orders are untracked once closed, canceled or expired, or with an OrderUnknown
+// event when QueryOrders did not return them for MaxMisses polls in a row.
+type OrderTracker struct {
+	api       *KrakenApi
+	Interval  time.Duration   // delay between polls, must be positive
+	Events    chan OrderEvent // events, in detection order
+	OnError   func(error)     // called from the goroutine when a poll fails (optional)
+	MaxMisses int             // polls an order may be missing before being untracked, OrderTrackerMaxMisses by default
+
+	mutex  sync.Mutex
+	orders map[string]*trackedOrder
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// Create an order tracker polling every interval. Events are buffered up to buffer events;
+// when the buffer is full, polling waits for events to be read.
+func (api *KrakenApi) NewOrderTracker(interval time.Duration, buffer int) *OrderTracker {
+	return &OrderTracker{
+		api:       api,
+		Interval:  interval,
+		Events:    make(chan OrderEvent, buffer),
+		MaxMisses: OrderTrackerMaxMisses,
+		orders:    make(map[string]*trackedOrder),
+	}
+}
+
+// Start tracking the given txids (ie: OrderResult.Txid)
+func (t *OrderTracker) Track(txids ...string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, txid := range txids {
+		if _, ok := t.orders[txid]; !ok {
+			t.orders[txid] = &trackedOrder{}
+		}
+	}
+}
+
+// Stop tracking the given txids.
+func (t *OrderTracker) Untrack(txids ...string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, txid := range txids {
+		delete(t.orders, txid)
+	}
+}
+
+// Returns the txids being tracked.
+func (t *OrderTracker) Tracked() []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	txids := make([]string, 0, len(t.orders))
+	for txid := range t.orders {
+		txids = append(txids, txid)
+	}
+
+	return txids
+}
+
+// Query all tracked orders once, and send events for changes.
+func (t *OrderTracker) Poll() error {
+	orders, err := t.api.queryOrders(t.Tracked())
+	if err != nil {
+		return err
+	}
+
+	for txid, order := range orders {
+		for _, event := range t.update(txid, order) {
+			if !t.send(event) {
+				return nil
+			}
+		}
+	}
+
+	for _, event := range t.missed(orders) {
+		if !t.send(event) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// Count a miss for tracked orders not returned by a poll, and untrack those missed MaxMisses times.
+func (t *OrderTracker) missed(orders map[string]Order) []OrderEvent {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	events := make([]OrderEvent, 0)
+
+	for txid, tracked := range t.orders {
+		if _, ok := orders[txid]; ok {
+			tracked.misses = 0
+			continue
+		}
+
+		tracked.misses++
+		if tracked.misses >= t.MaxMisses {
+			delete(t.orders, txid)
+			events = append(events, OrderEvent{Type: OrderUnknown, Txid: txid})
+		}
+	}
+
+	return events
+}
+
+// Update the state of an order, and returns the resulting events.
+func (t *OrderTracker) update(txid string, order Order) []OrderEvent {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	tracked, ok := t.orders[txid]
+	if !ok {
+		return nil
+	}
+
+	events := make([]OrderEvent, 0)
+	event := func(event_type OrderEventType) {
+		events = append(events, OrderEvent{event_type, txid, order})
+	}
+
+	if order.Status == "pending" {
+		return events
+	}
+
+	if !tracked.acknowledged {
+		tracked.acknowledged = true
+		event(OrderAcknowledged)
+	}
+
+	filled := order.VolExec > tracked.volExec
+	tracked.volExec = order.VolExec
+
+	switch order.Status {
+	case "open":
+		if filled {
+			event(OrderPartiallyFilled)
+		}
+	case "closed":
+		event(OrderFilled)
+	case "canceled":
+		if filled {
+			event(OrderPartiallyFilled)
+		}
+		event(OrderCanceled)
+	case "expired":
+		if filled {
+			event(OrderPartiallyFilled)
+		}
+		event(OrderExpired)
+	}
+
+	if order.Status != "open" {
+		delete(t.orders, txid)
+	}
+
+	return events
+}
+
+// Send an event, giving up if the tracker is being stopped.
+func (t *OrderTracker) send(event OrderEvent) bool {
+	t.mutex.Lock()
+	stop := t.stop
+	t.mutex.Unlock()
+
+	select {
+	case t.Events <- event:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// Start polling from a goroutine.
+func (t *OrderTracker) Start() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.stop != nil {
+		return fmt.Errorf("Order tracker already started")
+	}
+
+	if t.Interval <= 0 {
+		return fmt.Errorf("Invalid order tracker interval %s", t.Interval)
+	}
+
+	t.stop = make(chan struct{})
+	t.done = make(chan struct{})
+
+	go t.run(t.stop, t.done)
+
+	return nil
+}
+
+func (t *OrderTracker) run(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			err := t.Poll()
+			if err != nil && t.OnError != nil {
+				t.OnError(err)
+			}
+		}
+	}
+}
+
+// Stop polling. The Events channel is left open.
+func (t *OrderTracker) Stop() {
+	t.mutex.Lock()
+	stop, done := t.stop, t.done
+	t.mutex.Unlock()
+
+	if stop == nil {
+		return
+	}
+
+	close(stop)
+	<-done
+
+	t.mutex.Lock()
+	t.stop = nil
+	t.done = nil
+	t.mutex.Unlock()
+}
@@ -0,0 +1,89 @@
+package krakenapi
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestOrderTracker(t *testing.T) {
+	state := `"status":"pending","vol":"1","vol_exec":"0"`
+
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error":[],"result":{"OTXID":{` + state + `}}}`))
+	})
+	defer server.Close()
+
+	tracker := client.NewOrderTracker(time.Second, 10)
+	tracker.Track("OTXID")
+
+	steps := []struct {
+		state  string
+		events []OrderEventType
+	}{
+		{`"status":"pending","vol":"1","vol_exec":"0"`, []OrderEventType{}},
+		{`"status":"open","vol":"1","vol_exec":"0"`, []OrderEventType{OrderAcknowledged}},
+		{`"status":"open","vol":"1","vol_exec":"0.5"`, []OrderEventType{OrderPartiallyFilled}},
+		{`"status":"open","vol":"1","vol_exec":"0.5"`, []OrderEventType{}},
+		{`"status":"canceled","vol":"1","vol_exec":"0.7"`, []OrderEventType{OrderPartiallyFilled, OrderCanceled}},
+	}
+
+	for i, step := range steps {
+		state = step.state
+
+		err := tracker.Poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(tracker.Events) != len(step.events) {
+			t.Fatalf("step %d: expected %d events, got %d", i, len(step.events), len(tracker.Events))
+		}
+
+		for _, expected := range step.events {
+			event := <-tracker.Events
+			if event.Type != expected || event.Txid != "OTXID" {
+				t.Errorf("step %d: expected %s event, got %s for %s", i, expected, event.Type, event.Txid)
+			}
+		}
+	}
+
+	if len(tracker.Tracked()) != 0 {
+		t.Errorf("Canceled order should not be tracked anymore")
+	}
+}
+
+func TestOrderTrackerMisses(t *testing.T) {
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error":[],"result":{"OTXID":{"status":"open","vol":"1","vol_exec":"0"}}}`))
+	})
+	defer server.Close()
+
+	tracker := client.NewOrderTracker(0, 10)
+	if tracker.Start() == nil {
+		tracker.Stop()
+		t.Error("A zero interval should be refused")
+	}
+
+	tracker.MaxMisses = 2
+	tracker.Track("OTXID", "OMISSING")
+
+	for i := 0; i < 2; i++ {
+		err := tracker.Poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if event := <-tracker.Events; event.Type != OrderAcknowledged || event.Txid != "OTXID" {
+		t.Errorf("Unexpected event %s for %s", event.Type, event.Txid)
+	}
+
+	if event := <-tracker.Events; event.Type != OrderUnknown || event.Txid != "OMISSING" {
+		t.Errorf("Unexpected event %s for %s", event.Type, event.Txid)
+	}
+
+	if tracked := tracker.Tracked(); len(tracked) != 1 || tracked[0] != "OTXID" {
+		t.Errorf("Unexpected tracked orders %v", tracked)
+	}
+}
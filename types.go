@@ -1,9 +1,11 @@
 package krakenapi
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"time"
 )
 
 type KrakenResponse struct {
@@ -35,6 +37,7 @@ type AssetPair struct {
 	FeeVolumeCurrency string      `json:"fee_volume_currency"` // volume discount currency
 	MarginCall        int         `json:"margin_call"`         // margin call level
 	MarginStop        int         `json:"margin_stop"`         // stop-out/liquidation margin level
+	Wsname            string      `json:"wsname"`              // pair name as used by the websocket feeds (ie: XBT/EUR)
 }
 
 type TradeToday struct {
@@ -49,6 +52,61 @@ type RecentTrade struct {
 	Type      string
 	TradeType string
 	Misc      string
+	TradeId   int64 // 0 if not returned by Kraken
+}
+
+// Kraken returns trades as arrays of (<price>, <volume>, <time>, <buy/sell>, <market/limit>, <miscellaneous>, <trade id>).
+func (t *RecentTrade) UnmarshalJSON(b []byte) error {
+	var values []interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+
+	err := decoder.Decode(&values)
+	if err != nil {
+		return err
+	}
+
+	if len(values) < 6 {
+		return fmt.Errorf("Could not parse trade %s", b)
+	}
+
+	numbers := []*float64{&t.Price, &t.Volume, &t.Time}
+	for i, number := range numbers {
+		*number, err = parseNumber(values[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	t.Type, _ = values[3].(string)
+	t.TradeType, _ = values[4].(string)
+	t.Misc, _ = values[5].(string)
+
+	if len(values) > 6 {
+		if id, ok := values[6].(json.Number); ok {
+			t.TradeId, err = id.Int64()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Parse a number sent either as a json number or as a string.
+func parseNumber(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+
+	return 0, fmt.Errorf("Could not parse number %v", value)
 }
 
 type AskBid struct {
@@ -71,15 +129,94 @@ type Ticker struct {
 	OpeningPrice float64         `json:"o,string"` // today's opening price
 }
 
+// Time frame of OHLC candles, in minutes.
+type OHLCInterval int
+
+const (
+	OHLC_1M  OHLCInterval = 1
+	OHLC_5M  OHLCInterval = 5
+	OHLC_15M OHLCInterval = 15
+	OHLC_30M OHLCInterval = 30
+	OHLC_1H  OHLCInterval = 60
+	OHLC_4H  OHLCInterval = 240
+	OHLC_1D  OHLCInterval = 1440
+	OHLC_1W  OHLCInterval = 10080
+	OHLC_15D OHLCInterval = 21600
+)
+
+// Returns whether the interval is supported by Kraken.
+func (i OHLCInterval) Valid() bool {
+	switch i {
+	case OHLC_1M, OHLC_5M, OHLC_15M, OHLC_30M, OHLC_1H, OHLC_4H, OHLC_1D, OHLC_1W, OHLC_15D:
+		return true
+	}
+
+	return false
+}
+
+func (i OHLCInterval) Duration() time.Duration {
+	return time.Duration(i) * time.Minute
+}
+
 type OHLCEntry struct {
-	Time   float64
-	Open   float64
-	High   float64
-	Low    float64
-	Close  float64
-	VWAP   float64
-	Volume float64
-	Count  float64
+	Time      float64
+	Open      float64
+	High      float64
+	Low       float64
+	Close     float64
+	VWAP      float64
+	Volume    float64
+	Count     float64
+	Committed bool // false for the current, not-yet-committed candle
+}
+
+// Kraken returns candles as arrays of (<time>, <open>, <high>, <low>, <close>, <vwap>, <volume>, <count>).
+func (e *OHLCEntry) UnmarshalJSON(b []byte) error {
+	var values []json.Number
+
+	err := json.Unmarshal(b, &values)
+	if err != nil {
+		return err
+	}
+
+	if len(values) != 8 {
+		return fmt.Errorf("Could not parse OHLC entry %s", b)
+	}
+
+	fields := []*float64{&e.Time, &e.Open, &e.High, &e.Low, &e.Close, &e.VWAP, &e.Volume, &e.Count}
+	for i, field := range fields {
+		*field, err = values[i].Float64()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type OHLCResult struct {
+	Pairs map[string][]OHLCEntry // candles by pair name, the last one being the current, not-yet-committed one
+	Last  int64                  // id to be used as since when polling for new, committed OHLC data
+}
+
+// Returns the committed candles of pair.
+func (r *OHLCResult) Committed(pair string) []OHLCEntry {
+	entries := r.Pairs[pair]
+	if len(entries) > 0 && !entries[len(entries)-1].Committed {
+		entries = entries[:len(entries)-1]
+	}
+
+	return entries
+}
+
+// Returns the current, not-yet-committed candle of pair.
+func (r *OHLCResult) Current(pair string) (OHLCEntry, bool) {
+	entries := r.Pairs[pair]
+	if len(entries) == 0 || entries[len(entries)-1].Committed {
+		return OHLCEntry{}, false
+	}
+
+	return entries[len(entries)-1], true
 }
 
 type Trade struct {
@@ -105,6 +242,39 @@ type Trade struct {
 	Trades    []string `json:"trades"`         // list of closing trades for position (if available)
 }
 
+// Kraken returns time as a number, but as a string on the WebSocket feed.
+func (t *Trade) UnmarshalJSON(b []byte) error {
+	type trade Trade
+
+	aux := struct {
+		*trade
+		Time json.Number `json:"time"`
+	}{trade: (*trade)(t)}
+
+	err := json.Unmarshal(b, &aux)
+	if err != nil {
+		return err
+	}
+
+	return setNumber(&t.Time, aux.Time)
+}
+
+// Set a field from an optional number, leaving it unchanged when absent.
+func setNumber(field *float64, number json.Number) error {
+	if number == "" {
+		return nil
+	}
+
+	value, err := number.Float64()
+	if err != nil {
+		return err
+	}
+
+	*field = value
+
+	return nil
+}
+
 type PublicOrder struct {
 	Price  float64
 	Volume float64
@@ -129,9 +299,59 @@ type OrderResult struct {
 	Txid []string
 }
 
+type AddOrderBatchResult struct {
+	Orders []struct {
+		Descr struct {
+			Order string
+		} `json:"descr"` // order description info
+		Txid  string `json:"txid"`  // transaction id for order (if order was added successfully)
+		Error string `json:"error"` // error message (if order was rejected)
+	} `json:"orders"`
+}
+
+// Outcome of a single order of a batch: either Result or Err is set.
+type BatchOrder struct {
+	Result *OrderResult
+	Err    error
+}
+
+type EditOrderResult struct {
+	Descr struct {
+		Order string
+	} `json:"descr"` // order description info
+	Txid            string  `json:"txid"`             // new transaction id
+	OriginalTxid    string  `json:"originaltxid"`     // original transaction id
+	Volume          float64 `json:"volume,string"`    // updated volume
+	Price           float64 `json:"price,string"`     // updated price
+	Price2          float64 `json:"price2,string"`    // updated price2
+	OrdersCancelled int     `json:"orders_cancelled"` // number of orders cancelled (either 0 or 1)
+	Status          string  `json:"status"`           // status of order: ok / err
+	ErrorMessage    string  `json:"error_message"`    // error message if unsuccessful
+}
+
+type AmendOrderResult struct {
+	AmendId string `json:"amend_id"` // unique identifier of the amend transaction
+	Txid    string `json:"-"`        // transaction id of the amended order, unchanged by the amend
+}
+
 type CancelResult struct {
-	Count     int
-	IsPending bool
+	Count     int  `json:"count"`   // number of orders canceled
+	IsPending bool `json:"pending"` // if set, order(s) is/are pending cancellation
+}
+
+type CancelAllAfterResult struct {
+	CurrentTime string `json:"currentTime"` // timestamp (RFC3339 format) at which the request was received
+	TriggerTime string `json:"triggerTime"` // timestamp (RFC3339 format) after which all orders will be cancelled, unless the timer is extended or disabled
+}
+
+type BalanceEx struct {
+	Balance   float64 `json:"balance,string"`    // total balance of the asset
+	HoldTrade float64 `json:"hold_trade,string"` // amount held by open orders
+}
+
+type WebSocketsToken struct {
+	Token   string `json:"token"`   // token to authenticate private WebSocket subscriptions
+	Expires int    `json:"expires"` // seconds before the token expires, unless used to subscribe
 }
 
 type TradeBalance struct {
@@ -141,7 +361,7 @@ type TradeBalance struct {
 	N  float64 `json:"n,string"`  // unrealized net profit/loss of open positions
 	C  float64 `json:"c,string"`  // cost basis of open positions
 	V  float64 `json:"v,string"`  // current floating valuation of open positions
-	E  float64 `json:"v,string"`  // equity = trade balance + unrealized net profit/loss
+	E  float64 `json:"e,string"`  // equity = trade balance + unrealized net profit/loss
 	Mf float64 `json:"mf,string"` // free margin = equity - initial margin (maximum margin available to open new positions)
 	Ml float64 `json:"ml,string"` // margin level = (equity / initial margin) * 100
 }
@@ -149,6 +369,7 @@ type TradeBalance struct {
 type Order struct {
 	RefId      string     `json:"refid"`             // Referral order transaction id that created this order
 	Userref    string     `json:"userref"`           // user reference id
+	ClOrdId    string     `json:"cl_ord_id"`         // client order id
 	Status     string     `json:"status"`            // status of order: pending / open / closed / canceled / expired
 	Opentm     float64    `json:"opentm"`            // unix timestamp of when order was placed
 	Starttm    float64    `json:"starttm"`           // unix timestamp of order start time (or 0 if not set)
@@ -168,6 +389,59 @@ type Order struct {
 	Reason     string     `json:"reason"`            // Closed orders: additional info on status (if any)
 }
 
+/*
+Kraken returns userref as a number, stored as a string in Order.
+
+The WebSocket feed returns timestamps as strings and the average price as avg_price.
+Its updates only have changed fields: decoding them into an Order merges them, as
+fields absent from the message are left unchanged.
+*/
+func (o *Order) UnmarshalJSON(b []byte) error {
+	type order Order
+
+	aux := struct {
+		*order
+		Userref  json.RawMessage `json:"userref"`
+		Opentm   json.Number     `json:"opentm"`
+		Starttm  json.Number     `json:"starttm"`
+		Expiretm json.Number     `json:"expiretm"`
+		Closetm  json.Number     `json:"closetm"`
+		AvgPrice json.Number     `json:"avg_price"`
+	}{order: (*order)(o)}
+
+	err := json.Unmarshal(b, &aux)
+	if err != nil {
+		return err
+	}
+
+	numbers := map[*float64]json.Number{
+		&o.Opentm:   aux.Opentm,
+		&o.Starttm:  aux.Starttm,
+		&o.Expiretm: aux.Expiretm,
+		&o.Closetm:  aux.Closetm,
+		&o.Price:    aux.AvgPrice,
+	}
+
+	for field, number := range numbers {
+		err = setNumber(field, number)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case len(aux.Userref) == 0:
+	case string(aux.Userref) == "null":
+		o.Userref = ""
+	case aux.Userref[0] == '"':
+		return json.Unmarshal(aux.Userref, &o.Userref)
+	default:
+		o.Userref = string(aux.Userref)
+	}
+
+	return nil
+}
+
 type OrderDescr struct {
 	Pair      string  `json:"pair"`          // asset pair
 	Type      string  `json:"type"`          // type of order (buy/sell)
@@ -231,7 +505,7 @@ type LedgerResponse struct {
 }
 
 type TradeVolume struct {
-	Currency  string                    `json:"cuurrency"`     // volume currency
+	Currency  string                    `json:"currency"`      // volume currency
 	Volume    float64                   `json:"volume,string"` // current discount volume
 	Fees      map[string]TradeVolumeFee `json:"fees"`          // array of asset pairs and fee tier info (if requested)
 	FeesMaker map[string]TradeVolumeFee `json:"fees_maker"`    // array of asset pairs and maker fee tier info (if requested) for any pairs on maker/taker schedule
@@ -246,8 +520,9 @@ type TradeVolumeFee struct {
 	Tiervolume float64 `json:"tiervolume,string"` // volume level of current tier (if not fixed fee.  nil if at lowest fee tier)
 }
 
+// Kraken sends the whole lot volume as a string in REST results and as a number in WebSocket messages.
 func (t *AskBid) UnmarshalJSON(b []byte) error {
-	var out []string
+	var out []interface{}
 
 	err := json.Unmarshal(b, &out)
 	if err != nil {
@@ -258,17 +533,17 @@ func (t *AskBid) UnmarshalJSON(b []byte) error {
 		return fmt.Errorf("Invalid number of entries")
 	}
 
-	t.Price, err = strconv.ParseFloat(out[0], 64)
+	t.Price, err = parseNumber(out[0])
 	if err != nil {
 		return err
 	}
 
-	t.WholeLotVolume, err = strconv.ParseFloat(out[1], 64)
+	t.WholeLotVolume, err = parseNumber(out[1])
 	if err != nil {
 		return err
 	}
 
-	t.LotVolume, err = strconv.ParseFloat(out[2], 64)
+	t.LotVolume, err = parseNumber(out[2])
 	if err != nil {
 		return err
 	}
@@ -326,6 +601,7 @@ func (t *TodayH24Float64) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// Kraken sends the timestamp as a number in REST results and as a string in WebSocket messages.
 func (t *PublicOrder) UnmarshalJSON(b []byte) error {
 	var out []interface{}
 
@@ -334,17 +610,21 @@ func (t *PublicOrder) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
-	t.Price, err = strconv.ParseFloat(out[0].(string), 64)
+	if len(out) < 3 {
+		return fmt.Errorf("Invalid number of entries")
+	}
+
+	t.Price, err = parseNumber(out[0])
 	if err != nil {
 		return err
 	}
 
-	t.Volume, err = strconv.ParseFloat(out[1].(string), 64)
+	t.Volume, err = parseNumber(out[1])
 	if err != nil {
 		return err
 	}
 
-	t.Time = out[2].(float64)
+	t.Time, err = parseNumber(out[2])
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,112 @@
+package krakenapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Delay between two QueryOrders calls when waiting for an order.
+var WaitPollInterval = 2 * time.Second
+
+func isFinalStatus(status string) bool {
+	return status == "closed" || status == "canceled" || status == "expired"
+}
+
+// Query a single order, including its trades.
+func (api *KrakenApi) queryOrder(txid string) (*Order, error) {
+	orders, err := api.ApiQueryOrders(true, "", txid)
+	if err != nil {
+		return nil, err
+	}
+
+	order, ok := (*orders)[txid]
+	if !ok {
+		return nil, fmt.Errorf("Unknown order %s", txid)
+	}
+
+	return &order, nil
+}
+
+// Query any number of orders, including their trades, QUERY_ORDERS_MAX_TXIDS at a time.
+func (api *KrakenApi) queryOrders(txids []string) (map[string]Order, error) {
+	out := make(map[string]Order)
+
+	for start := 0; start < len(txids); start += QUERY_ORDERS_MAX_TXIDS {
+		end := start + QUERY_ORDERS_MAX_TXIDS
+		if end > len(txids) {
+			end = len(txids)
+		}
+
+		orders, err := api.ApiQueryOrders(true, "", strings.Join(txids[start:end], ","))
+		if err != nil {
+			return nil, err
+		}
+
+		for txid, order := range *orders {
+			out[txid] = order
+		}
+	}
+
+	return out, nil
+}
+
+/*
+Poll an order with QueryOrders until done returns true.
+
+Returns the last known state of the order, with its trades. If the context is
+done first, the last known state is returned along with the context's error.
+If the order reaches a final status (closed, canceled, expired) before done
+returns true, an error is returned along with the order.
+*/
+func (api *KrakenApi) WaitForOrder(ctx context.Context, txid string, done func(*Order) bool) (*Order, error) {
+	var last *Order
+
+	for {
+		order, err := api.queryOrder(txid)
+		if err != nil {
+			return last, err
+		}
+
+		last = order
+
+		if done(order) {
+			return order, nil
+		}
+
+		if isFinalStatus(order.Status) {
+			return order, fmt.Errorf("Order %s is %s", txid, order.Status)
+		}
+
+		select {
+		case <-ctx.Done():
+			return last, ctx.Err()
+		case <-time.After(WaitPollInterval):
+		}
+	}
+}
+
+// Wait until the order reaches one of the given statuses (pending, open, closed, canceled, expired).
+func (api *KrakenApi) WaitForStatus(ctx context.Context, txid string, statuses ...string) (*Order, error) {
+	return api.WaitForOrder(ctx, txid, func(order *Order) bool {
+		for _, status := range statuses {
+			if order.Status == status {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// Wait until at least volume was executed, or until the order is closed if volume is 0.
+func (api *KrakenApi) WaitForFill(ctx context.Context, txid string, volume float64) (*Order, error) {
+	return api.WaitForOrder(ctx, txid, func(order *Order) bool {
+		if volume == 0 {
+			return order.Status == "closed"
+		}
+
+		return order.VolExec >= volume
+	})
+}
@@ -0,0 +1,59 @@
+package krakenapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWaitForFill(t *testing.T) {
+	interval := WaitPollInterval
+	WaitPollInterval = time.Millisecond
+	defer func() { WaitPollInterval = interval }()
+
+	states := []string{
+		`"status":"open","vol":"1","vol_exec":"0"`,
+		`"status":"open","vol":"1","vol_exec":"0.5"`,
+		`"status":"closed","vol":"1","vol_exec":"1","trades":["TTXID"]`,
+	}
+	queries := 0
+
+	client, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		state := states[len(states)-1]
+		if queries < len(states) {
+			state = states[queries]
+		}
+		queries++
+
+		w.Write([]byte(`{"error":[],"result":{"OTXID":{` + state + `}}}`))
+	})
+	defer server.Close()
+
+	order, err := client.WaitForFill(context.Background(), "OTXID", 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if order.VolExec != 0.5 {
+		t.Errorf("Unexpected executed volume %f", order.VolExec)
+	}
+
+	order, err = client.WaitForFill(context.Background(), "OTXID", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if order.Status != "closed" || len(order.Trades) != 1 {
+		t.Errorf("Unexpected order %v", order)
+	}
+
+	queries = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = client.WaitForStatus(ctx, "OTXID", "expired")
+	if err == nil {
+		t.Errorf("Waiting for an order reaching another final status should fail")
+	}
+}
@@ -0,0 +1,613 @@
+package krakenapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	WS_URL_PUBLIC = "wss://ws.kraken.com"
+)
+
+// Channel subscription. Interval is used by ohlc, Depth by book.
+type WSSubscription struct {
+	Name     string `json:"name"` // ticker, ohlc, trade, spread, book, or private ownTrades, openOrders, balances
+	Interval int    `json:"interval,omitempty"`
+	Depth    int    `json:"depth,omitempty"`
+	Token    string `json:"token,omitempty"` // set by Subscribe for private channels
+}
+
+type WSTicker struct {
+	Pair   string
+	Ticker Ticker
+}
+
+// Candle of the current interval, sent each time it changes. Candle.Committed is never set.
+type WSCandle struct {
+	Pair     string
+	Interval OHLCInterval
+	EndTime  float64 // end time of the interval
+	Candle   OHLCEntry
+}
+
+type WSTrades struct {
+	Pair   string
+	Trades []RecentTrade
+}
+
+type WSSpread struct {
+	Pair      string
+	Spread    Spread
+	BidVolume float64
+	AskVolume float64
+}
+
+// Book snapshot or update. Updated levels with a zero volume are removed (see OrderBook.Update).
+type WSBook struct {
+	Pair     string
+	Snapshot bool // Asks and Bids are the whole book (see OrderBook.Load)
+	Asks     []PublicOrder
+	Bids     []PublicOrder
+	Checksum string // CRC32 of the top 10 levels after the update, empty for snapshots
+}
+
+// Error returned by Kraken for a WebSocket request, ie: a refused subscription.
+type WSError struct {
+	Event   string
+	Pair    string
+	Message string
+}
+
+func (e *WSError) Error() string {
+	return fmt.Sprintf("WebSocket %s failed for %s (%s)", e.Event, e.Pair, e.Message)
+}
+
+type wsRequest struct {
+	Event        string          `json:"event"`
+	Pair         []string        `json:"pair,omitempty"`
+	Subscription *WSSubscription `json:"subscription,omitempty"`
+}
+
+// Event messages sent by Kraken (subscriptionStatus, heartbeat, systemStatus...),
+// and messages of the v2 feed (method responses and channel data).
+type wsEvent struct {
+	Event        string         `json:"event"`
+	Status       string         `json:"status"`
+	Pair         string         `json:"pair"`
+	ChannelName  string         `json:"channelName"`
+	ErrorMessage string         `json:"errorMessage"`
+	Subscription WSSubscription `json:"subscription"`
+	Version      string         `json:"version"`
+
+	Method  string          `json:"method"`
+	ReqId   int64           `json:"req_id"`
+	Success *bool           `json:"success"`
+	Error   string          `json:"error"`
+	Result  json.RawMessage `json:"result"`
+	Channel string          `json:"channel"`
+	Type    string          `json:"type"`
+	Data    json.RawMessage `json:"data"`
+}
+
+/*
+WSClient receives Kraken's public WebSocket feed, or its private feed when
+created by NewPrivateWSClient.
+
+Subscribe to channels of pairs (using their WebSocket names, see AssetPair.Wsname),
+then read decoded messages from the channel of each subscription type. Channels
+are buffered; when a buffer is full, reading messages waits for it to be read.
+
+The connection is pinged every PingInterval, and considered stalled when nothing
+is received for StallTimeout. When the connection is lost or stalls, the client
+reconnects with an exponential backoff and subscribes again to its channels.
+Settings must be changed before calling Connect.
+*/
+type WSClient struct {
+	URL     string
+	Dialer  *websocket.Dialer
+	OnError func(error) // called from the reading goroutine on errors (optional)
+
+	Reconnect         bool          // reconnect when the connection is lost (default true)
+	ReconnectDelay    time.Duration // delay before the first reconnection attempt, doubled after each failure (at least WSMinReconnectDelay)
+	MaxReconnectDelay time.Duration // maximum delay between reconnection attempts
+	PingInterval      time.Duration // 0 to disable pings
+	StallTimeout      time.Duration // 0 to disable stall detection
+
+	Status chan WSStatus // system status, sent on connection and when it changes
+
+	Tickers chan WSTicker
+	Candles chan WSCandle
+	Trades  chan WSTrades
+	Spreads chan WSSpread
+	Books   chan WSBook
+
+	Tokens     *WSTokenSource // authenticates private subscriptions, nil on the public feed
+	OwnTrades  chan WSOwnTrades
+	OpenOrders chan WSOrder
+	Balances   chan WSBalances
+
+	mutex  sync.Mutex
+	write  sync.Mutex
+	conn   *websocket.Conn
+	stop   chan struct{}
+	done   chan struct{}
+	orders map[string]Order // open orders, only used by the reading goroutine
+
+	reqid   int64                  // last request id
+	pending map[int64]chan wsEvent // requests waiting for their response, by request id
+
+	api           *KrakenApi                  // used to resync open orders after a gap (private feed)
+	subscriptions map[string]*wsSubscriptions // subscribed channels, replayed after reconnections
+	sequences     map[string]int              // last sequence of private channels, only used by the reading goroutine
+	ordersGap     bool                        // open orders must be resynced, only used by the reading goroutine
+}
+
+// Create a WebSocket client for url (WS_URL_PUBLIC if empty), with channels buffering up to buffer messages.
+func NewWSClient(url string, buffer int) *WSClient {
+	if url == "" {
+		url = WS_URL_PUBLIC
+	}
+
+	return &WSClient{
+		URL:               url,
+		Dialer:            websocket.DefaultDialer,
+		Reconnect:         true,
+		ReconnectDelay:    time.Second,
+		MaxReconnectDelay: time.Minute,
+		PingInterval:      5 * time.Second,
+		StallTimeout:      15 * time.Second,
+		Status:            make(chan WSStatus, buffer),
+		Tickers:           make(chan WSTicker, buffer),
+		Candles:           make(chan WSCandle, buffer),
+		Trades:            make(chan WSTrades, buffer),
+		Spreads:           make(chan WSSpread, buffer),
+		Books:             make(chan WSBook, buffer),
+		OwnTrades:         make(chan WSOwnTrades, buffer),
+		OpenOrders:        make(chan WSOrder, buffer),
+		Balances:          make(chan WSBalances, buffer),
+	}
+}
+
+// Connect, start reading messages from a goroutine, and subscribe to the channels subscribed before.
+func (c *WSClient) Connect(ctx context.Context) error {
+	c.mutex.Lock()
+
+	if c.conn != nil {
+		c.mutex.Unlock()
+		return fmt.Errorf("WebSocket client already connected")
+	}
+
+	conn, _, err := c.Dialer.DialContext(ctx, c.URL, nil)
+	if err != nil {
+		c.mutex.Unlock()
+		return err
+	}
+
+	c.conn = conn
+	c.orders = make(map[string]Order)
+	c.sequences = make(map[string]int)
+	c.ordersGap = false
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+
+	go c.run(conn, c.stop, c.done)
+
+	c.mutex.Unlock()
+
+	return c.replay()
+}
+
+// Close the connection, waiting for the reading goroutine to exit.
+func (c *WSClient) Close() error {
+	c.mutex.Lock()
+	conn, stop, done := c.conn, c.stop, c.done
+	c.conn = nil
+	if conn != nil {
+		close(stop)
+	}
+	c.mutex.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+
+	c.write.Lock()
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	c.write.Unlock()
+
+	err := conn.Close()
+	<-done
+
+	return err
+}
+
+func (c *WSClient) send(message interface{}) error {
+	c.mutex.Lock()
+	conn := c.conn
+	c.mutex.Unlock()
+
+	if conn == nil {
+		return fmt.Errorf("WebSocket client not connected")
+	}
+
+	c.write.Lock()
+	defer c.write.Unlock()
+
+	return conn.WriteJSON(message)
+}
+
+/*
+Subscribe to a channel for pairs. Refused subscriptions are reported to OnError as *WSError.
+Private channels are subscribed without pairs.
+
+The subscription is kept even if it could not be sent, and made again on each (re)connection.
+*/
+func (c *WSClient) Subscribe(subscription WSSubscription, pairs ...string) error {
+	c.remember(subscription, pairs)
+
+	return c.request("subscribe", subscription, pairs)
+}
+
+func (c *WSClient) Unsubscribe(subscription WSSubscription, pairs ...string) error {
+	c.forgetSubscription(subscription, pairs)
+
+	return c.request("unsubscribe", subscription, pairs)
+}
+
+func (c *WSClient) request(event string, subscription WSSubscription, pairs []string) error {
+	if !wsPrivateChannels[subscription.Name] {
+		return c.send(wsRequest{Event: event, Pair: pairs, Subscription: &subscription})
+	}
+
+	token, err := c.token()
+	if err != nil {
+		return err
+	}
+
+	if subscription.Name == "balances" {
+		return c.send(wsRequestV2{Method: event, Params: wsParamsV2{Channel: subscription.Name, Token: token}})
+	}
+
+	subscription.Token = token
+
+	return c.send(wsRequest{Event: event, Subscription: &subscription})
+}
+
+func (c *WSClient) error(err error) {
+	if c.OnError != nil {
+		c.OnError(err)
+	}
+}
+
+// Read messages until the client is closed, reconnecting when the connection is lost.
+func (c *WSClient) run(conn *websocket.Conn, stop, done chan struct{}) {
+	defer close(done)
+
+	for conn != nil {
+		err := c.read(conn, stop)
+		c.failPending()
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		c.error(err)
+
+		if !c.Reconnect {
+			return
+		}
+
+		conn = c.reconnect(stop)
+	}
+}
+
+// Read messages from conn, until it fails or stalls.
+func (c *WSClient) read(conn *websocket.Conn, stop chan struct{}) error {
+	reading := make(chan struct{})
+	defer close(reading)
+
+	go c.ping(conn, reading)
+
+	conn.SetPongHandler(func(string) error {
+		c.extendDeadline(conn)
+		return nil
+	})
+
+	for {
+		c.extendDeadline(conn)
+
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		err = c.dispatch(message, stop)
+		if err != nil {
+			c.error(err)
+		}
+	}
+}
+
+func (c *WSClient) dispatch(message []byte, stop chan struct{}) error {
+	if strings.HasPrefix(strings.TrimSpace(string(message)), "{") {
+		var event wsEvent
+
+		err := json.Unmarshal(message, &event)
+		if err != nil {
+			return err
+		}
+
+		return c.handleEvent(event, stop)
+	}
+
+	var fields []json.RawMessage
+
+	err := json.Unmarshal(message, &fields)
+	if err != nil {
+		return err
+	}
+
+	// Private feed: [payload, channelName, {"sequence": n}]
+	if len(fields) == 3 && strings.HasPrefix(string(fields[2]), "{") {
+		var channel string
+		var sequence wsSequence
+
+		err = json.Unmarshal(fields[1], &channel)
+		if err != nil {
+			return err
+		}
+
+		err = json.Unmarshal(fields[2], &sequence)
+		if err != nil {
+			return err
+		}
+
+		return c.handlePrivate(channel, fields[0], sequence.Sequence, stop)
+	}
+
+	// [channelID, payload..., channelName, pair]
+	if len(fields) < 4 {
+		return fmt.Errorf("Could not parse WebSocket message %s", message)
+	}
+
+	var channel, pair string
+
+	err = json.Unmarshal(fields[len(fields)-2], &channel)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(fields[len(fields)-1], &pair)
+	if err != nil {
+		return err
+	}
+
+	return c.handleData(channel, pair, fields[1:len(fields)-2], stop)
+}
+
+func (c *WSClient) handleEvent(event wsEvent, stop chan struct{}) error {
+	if event.ReqId != 0 && c.respond(event) {
+		return nil
+	}
+
+	if event.Method != "" && event.Success != nil && !*event.Success {
+		return &WSError{event.Method, event.Channel, event.Error}
+	}
+
+	switch event.Channel {
+	case "balances":
+		return c.handleBalances(event, stop)
+	case "status":
+		return c.handleStatusV2(event, stop)
+	}
+
+	switch event.Event {
+	case "systemStatus":
+		select {
+		case c.Status <- WSStatus{event.Status, event.Version}:
+		case <-stop:
+		}
+
+	case "subscriptionStatus":
+		if event.Status == "error" {
+			return &WSError{"subscribe", event.Pair, event.ErrorMessage}
+		}
+	case "error":
+		return &WSError{event.Event, event.Pair, event.ErrorMessage}
+	}
+
+	return nil
+}
+
+func (c *WSClient) handleData(channel, pair string, payloads []json.RawMessage, stop chan struct{}) error {
+	name := channel
+	if i := strings.Index(channel, "-"); i >= 0 {
+		name = channel[:i]
+	}
+
+	switch name {
+	case "ticker":
+		ticker, err := decodeWSTicker(payloads[0])
+		if err != nil {
+			return err
+		}
+
+		select {
+		case c.Tickers <- WSTicker{pair, ticker}:
+		case <-stop:
+		}
+
+	case "ohlc":
+		candle, err := decodeWSCandle(payloads[0])
+		if err != nil {
+			return err
+		}
+
+		candle.Pair = pair
+		interval, _ := strconv.Atoi(strings.TrimPrefix(channel, "ohlc-"))
+		candle.Interval = OHLCInterval(interval)
+
+		select {
+		case c.Candles <- candle:
+		case <-stop:
+		}
+
+	case "trade":
+		var trades []RecentTrade
+
+		err := json.Unmarshal(payloads[0], &trades)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case c.Trades <- WSTrades{pair, trades}:
+		case <-stop:
+		}
+
+	case "spread":
+		spread, err := decodeWSSpread(payloads[0])
+		if err != nil {
+			return err
+		}
+
+		spread.Pair = pair
+
+		select {
+		case c.Spreads <- spread:
+		case <-stop:
+		}
+
+	case "book":
+		book, err := decodeWSBook(payloads)
+		if err != nil {
+			return err
+		}
+
+		book.Pair = pair
+
+		select {
+		case c.Books <- book:
+		case <-stop:
+		}
+
+	default:
+		return fmt.Errorf("Unknown WebSocket channel %s", channel)
+	}
+
+	return nil
+}
+
+// The WebSocket ticker has the REST ticker fields, but today's and last 24 hours opening prices.
+func decodeWSTicker(payload json.RawMessage) (Ticker, error) {
+	var ticker struct {
+		Ticker
+		OpeningPrice TodayH24Float64 `json:"o"`
+	}
+
+	err := json.Unmarshal(payload, &ticker)
+	if err != nil {
+		return Ticker{}, err
+	}
+
+	ticker.Ticker.OpeningPrice = ticker.OpeningPrice[0]
+
+	return ticker.Ticker, nil
+}
+
+// (<time>, <etime>, <open>, <high>, <low>, <close>, <vwap>, <volume>, <count>)
+func decodeWSCandle(payload json.RawMessage) (WSCandle, error) {
+	var values []json.Number
+
+	err := json.Unmarshal(payload, &values)
+	if err != nil {
+		return WSCandle{}, err
+	}
+
+	if len(values) != 9 {
+		return WSCandle{}, fmt.Errorf("Invalid number of entries")
+	}
+
+	var candle WSCandle
+	entry := &candle.Candle
+
+	fields := []*float64{&entry.Time, &candle.EndTime, &entry.Open, &entry.High, &entry.Low, &entry.Close, &entry.VWAP, &entry.Volume, &entry.Count}
+	for i, field := range fields {
+		*field, err = values[i].Float64()
+		if err != nil {
+			return WSCandle{}, err
+		}
+	}
+
+	return candle, nil
+}
+
+// (<bid>, <ask>, <timestamp>, <bid volume>, <ask volume>)
+func decodeWSSpread(payload json.RawMessage) (WSSpread, error) {
+	var values []json.Number
+
+	err := json.Unmarshal(payload, &values)
+	if err != nil {
+		return WSSpread{}, err
+	}
+
+	if len(values) != 5 {
+		return WSSpread{}, fmt.Errorf("Invalid number of entries")
+	}
+
+	var spread WSSpread
+
+	fields := []*float64{&spread.Spread.Bid, &spread.Spread.Ask, &spread.Spread.Time, &spread.BidVolume, &spread.AskVolume}
+	for i, field := range fields {
+		*field, err = values[i].Float64()
+		if err != nil {
+			return WSSpread{}, err
+		}
+	}
+
+	return spread, nil
+}
+
+// Snapshots have as & bs, updates a and/or b, possibly split in two payloads.
+func decodeWSBook(payloads []json.RawMessage) (WSBook, error) {
+	var book WSBook
+
+	for _, payload := range payloads {
+		var levels struct {
+			As []PublicOrder `json:"as"`
+			Bs []PublicOrder `json:"bs"`
+			A  []PublicOrder `json:"a"`
+			B  []PublicOrder `json:"b"`
+			C  string        `json:"c"`
+		}
+
+		err := json.Unmarshal(payload, &levels)
+		if err != nil {
+			return WSBook{}, err
+		}
+
+		if levels.As != nil || levels.Bs != nil {
+			book.Snapshot = true
+		}
+
+		book.Asks = append(book.Asks, levels.As...)
+		book.Asks = append(book.Asks, levels.A...)
+		book.Bids = append(book.Bids, levels.Bs...)
+		book.Bids = append(book.Bids, levels.B...)
+
+		if levels.C != "" {
+			book.Checksum = levels.C
+		}
+	}
+
+	return book, nil
+}
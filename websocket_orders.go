@@ -0,0 +1,545 @@
+package krakenapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default time to wait for the response to a WebSocket order request.
+var WSOrderTimeout = 5 * time.Second
+
+// Returned when Kraken did not respond to a WebSocket request in time.
+// The request may still have been processed (ie: an order placed).
+type WSTimeoutError struct {
+	Method string
+	ReqId  int64
+}
+
+func (e *WSTimeoutError) Error() string {
+	return fmt.Sprintf("No response to WebSocket %s request %d", e.Method, e.ReqId)
+}
+
+type wsCallV2 struct {
+	Method string                 `json:"method"`
+	Params map[string]interface{} `json:"params"`
+	ReqId  int64                  `json:"req_id"`
+}
+
+/*
+Send a request on the v2 feed and wait up to timeout for the response with the same req_id.
+
+Returns the result of the response, an *ApiError if Kraken refused the request, or
+a *WSTimeoutError if it did not respond in time.
+*/
+func (c *WSClient) call(method string, params map[string]interface{}, timeout time.Duration) (json.RawMessage, error) {
+	token, err := c.token()
+	if err != nil {
+		return nil, err
+	}
+
+	params["token"] = token
+
+	response := make(chan wsEvent, 1)
+
+	c.mutex.Lock()
+	c.reqid++
+	reqid := c.reqid
+	if c.pending == nil {
+		c.pending = make(map[int64]chan wsEvent)
+	}
+	c.pending[reqid] = response
+	c.mutex.Unlock()
+
+	err = c.send(wsCallV2{method, params, reqid})
+	if err != nil {
+		c.forget(reqid)
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case event, ok := <-response:
+		if !ok {
+			return nil, fmt.Errorf("WebSocket connection closed before %s response", method)
+		}
+
+		if event.Success == nil || !*event.Success {
+			return nil, &ApiError{[]string{event.Error}}
+		}
+
+		return event.Result, nil
+
+	case <-timer.C:
+		c.forget(reqid)
+		return nil, &WSTimeoutError{method, reqid}
+	}
+}
+
+func (c *WSClient) forget(reqid int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.pending, reqid)
+}
+
+// Pass a response to the request waiting for it. Returns false if none is.
+func (c *WSClient) respond(event wsEvent) bool {
+	c.mutex.Lock()
+	response, ok := c.pending[event.ReqId]
+	delete(c.pending, event.ReqId)
+	c.mutex.Unlock()
+
+	if ok {
+		response <- event
+	}
+
+	return ok
+}
+
+// Fail the requests waiting for a response, once the connection is closed.
+func (c *WSClient) failPending() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, response := range c.pending {
+		close(response)
+	}
+
+	c.pending = nil
+}
+
+// Returns an RFC3339 time from a starttm or expiretm value (0, +<n> or unix timestamp).
+func wsTime(value string) (string, error) {
+	if value == "" || value == "0" {
+		return "", nil
+	}
+
+	if strings.HasPrefix(value, "+") {
+		seconds, err := strconv.Atoi(value[1:])
+		if err != nil {
+			return "", err
+		}
+
+		return time.Now().Add(time.Duration(seconds) * time.Second).UTC().Format(time.RFC3339), nil
+	}
+
+	timestamp, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return "", err
+	}
+
+	return time.Unix(int64(timestamp), 0).UTC().Format(time.RFC3339), nil
+}
+
+// Returns the add_order parameters of the v2 WebSocket feed for this order.
+func (order *OrderRequest) wsParams() (map[string]interface{}, error) {
+	params := map[string]interface{}{
+		"symbol":     order.Pair,
+		"side":       order.Type,
+		"order_type": order.OrderType,
+	}
+
+	viqc := false
+
+	for _, flag := range order.flags() {
+		switch flag {
+		case "post":
+			params["post_only"] = true
+		case "fcib":
+			params["fee_preference"] = "base"
+		case "fciq":
+			params["fee_preference"] = "quote"
+		case "nompp":
+			params["no_mpp"] = true
+		case "viqc":
+			viqc = true
+		default:
+			return nil, &OrderValidationError{"oflags", fmt.Sprintf("unknown order flag %s", flag)}
+		}
+	}
+
+	if viqc {
+		params["cash_order_qty"] = order.Volume
+	} else {
+		params["order_qty"] = order.Volume
+	}
+
+	trailing := strings.HasPrefix(order.OrderType, "trailing-stop")
+	limit_price, trigger_price := order.limitAndTriggerPrices()
+
+	if limit_price != 0 {
+		params["limit_price"] = limit_price
+		if trailing {
+			params["limit_price_type"] = "quote"
+		}
+	}
+
+	if triggeredOrderTypes[order.OrderType] {
+		triggers := map[string]interface{}{"price": trigger_price}
+		if trailing {
+			triggers["price_type"] = "quote"
+		}
+		if order.Trigger != "" {
+			triggers["reference"] = string(order.Trigger)
+		}
+
+		params["triggers"] = triggers
+	}
+
+	if order.Leverage != "" && order.Leverage != "none" {
+		params["margin"] = true
+	}
+
+	if order.TimeInForce != "" {
+		params["time_in_force"] = strings.ToLower(string(order.TimeInForce))
+	}
+
+	if order.ReduceOnly {
+		params["reduce_only"] = true
+	}
+
+	if order.StpType != "" {
+		params["stp_type"] = strings.Replace(string(order.StpType), "-", "_", -1)
+	}
+
+	times := map[string]string{"effective_time": order.Starttm, "expire_time": order.Expiretm}
+	for name, value := range times {
+		formatted, err := wsTime(value)
+		if err != nil {
+			return nil, &OrderValidationError{name, fmt.Sprintf("invalid time %s", value)}
+		}
+		if formatted != "" {
+			params[name] = formatted
+		}
+	}
+
+	if order.Userref != "" {
+		userref, err := strconv.Atoi(order.Userref)
+		if err != nil {
+			return nil, &OrderValidationError{"userref", fmt.Sprintf("not a number: %s", order.Userref)}
+		}
+
+		params["order_userref"] = userref
+	}
+
+	if order.ClOrdId != "" {
+		params["cl_ord_id"] = order.ClOrdId
+	}
+
+	if order.Validate {
+		params["validate"] = true
+	}
+
+	return params, nil
+}
+
+// add_order parameters which edit_order accepts.
+var wsEditParams = map[string]bool{
+	"symbol":         true,
+	"order_qty":      true,
+	"limit_price":    true,
+	"post_only":      true,
+	"reduce_only":    true,
+	"fee_preference": true,
+	"no_mpp":         true,
+	"order_userref":  true,
+	"validate":       true,
+}
+
+/*
+OrderTransport sends orders to Kraken, either with REST calls (RestOrderTransport)
+or on the WebSocket feed (WSOrderTransport), so that strategy code does not depend
+on how orders are sent.
+
+Orders are checked with OrderRequest.Check and KrakenApi.PreTradeCheck before being
+added, and refused if the client mode or its kill switch does not allow the call.
+*/
+type OrderTransport interface {
+	AddOrder(order *OrderRequest) (*OrderResult, error)
+	EditOrder(txid string, order *OrderRequest) (*EditOrderResult, error)
+	AmendOrder(txid string, order *OrderRequest) (*AmendOrderResult, error)
+	CancelOrder(txid string) (*CancelResult, error)
+}
+
+// Sends orders with AddOrder, EditOrder, AmendOrder & CancelOrder REST calls.
+type RestOrderTransport struct {
+	api *KrakenApi
+}
+
+func (api *KrakenApi) NewRestOrderTransport() *RestOrderTransport {
+	return &RestOrderTransport{api: api}
+}
+
+func (t *RestOrderTransport) AddOrder(order *OrderRequest) (*OrderResult, error) {
+	return t.api.ApiAddOrderRequest(order)
+}
+
+func (t *RestOrderTransport) EditOrder(txid string, order *OrderRequest) (*EditOrderResult, error) {
+	return t.api.ApiEditOrder(txid, order)
+}
+
+func (t *RestOrderTransport) AmendOrder(txid string, order *OrderRequest) (*AmendOrderResult, error) {
+	return t.api.ApiAmendOrder(txid, order)
+}
+
+func (t *RestOrderTransport) CancelOrder(txid string) (*CancelResult, error) {
+	return t.api.ApiCancelOrder(txid)
+}
+
+/*
+WSOrderTransport sends orders with add_order, edit_order, amend_order & cancel_order
+requests on the v2 WebSocket feed, which have a lower latency than REST calls.
+
+Client must be connected to WS_URL_PRIVATE_V2, with a token source (see
+NewPrivateWSClient). Each request waits up to Timeout for its response, after which
+a *WSTimeoutError is returned: the request may still have been processed.
+
+Pairs are given as for REST calls (any alias, see Registry) and translated to their
+v2 symbol (ie: XXBTZEUR -> BTC/EUR) with Registry.
+*/
+type WSOrderTransport struct {
+	Client   *WSClient
+	Timeout  time.Duration
+	Registry *Registry // resolves order pairs to v2 symbols
+
+	api *KrakenApi
+}
+
+func (api *KrakenApi) NewWSOrderTransport(client *WSClient) *WSOrderTransport {
+	return &WSOrderTransport{
+		Client:   client,
+		Timeout:  WSOrderTimeout,
+		Registry: NewRegistry(api, 24*time.Hour),
+		api:      api,
+	}
+}
+
+// Returns the v2 parameters of an order, its pair translated to a v2 symbol.
+func (t *WSOrderTransport) params(order *OrderRequest) (map[string]interface{}, error) {
+	params, err := order.wsParams()
+	if err != nil {
+		return nil, err
+	}
+
+	if order.Pair == "" {
+		delete(params, "symbol")
+		return params, nil
+	}
+
+	symbol, err := t.Registry.SymbolV2(order.Pair)
+	if err != nil {
+		return nil, &OrderValidationError{"pair", err.Error()}
+	}
+
+	params["symbol"] = symbol
+
+	return params, nil
+}
+
+func (t *WSOrderTransport) AddOrder(order *OrderRequest) (*OrderResult, error) {
+	err := order.Check()
+	if err != nil {
+		return nil, err
+	}
+
+	if t.api.PreTradeCheck != nil {
+		err = t.api.PreTradeCheck(order)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = t.api.checkEndpoint(URL_PRIVATE_ADD_ORDER)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := t.params(order)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := t.Client.call("add_order", params, t.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		OrderId string `json:"order_id"`
+	}
+
+	err = json.Unmarshal(content, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &OrderResult{}
+	if result.OrderId != "" {
+		out.Txid = []string{result.OrderId}
+	}
+
+	return out, nil
+}
+
+// Edit an order. Volume and prices of the result are only set when Kraken's response has them.
+func (t *WSOrderTransport) EditOrder(txid string, order *OrderRequest) (*EditOrderResult, error) {
+	err := order.Check()
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.api.checkEndpoint(URL_PRIVATE_EDIT_ORDER)
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.api.checkOrderChange(txid, order.editChange())
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := t.params(order)
+	if err != nil {
+		return nil, err
+	}
+
+	for name := range params {
+		if !wsEditParams[name] {
+			delete(params, name)
+		}
+	}
+
+	if order.Volume == 0 {
+		delete(params, "order_qty")
+	}
+
+	if _, trigger_price := order.limitAndTriggerPrices(); trigger_price != 0 && triggeredOrderTypes[order.OrderType] {
+		params["trigger_price"] = trigger_price
+	}
+
+	params["order_id"] = txid
+
+	content, err := t.Client.call("edit_order", params, t.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		OrderId         string  `json:"order_id"`
+		OriginalOrderId string  `json:"original_order_id"`
+		OrderQty        float64 `json:"order_qty"`
+		LimitPrice      float64 `json:"limit_price"`
+		TriggerPrice    float64 `json:"trigger_price"`
+	}
+
+	err = json.Unmarshal(content, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.OrderId == "" {
+		return nil, fmt.Errorf("Could not edit order %s: no order id in the response", txid)
+	}
+
+	edit := &EditOrderResult{
+		Txid:         result.OrderId,
+		OriginalTxid: result.OriginalOrderId,
+		Volume:       result.OrderQty,
+		Status:       "ok",
+	}
+
+	// Same as EditOrder: price is the trigger price of triggered orders, price2 their limit price
+	if result.TriggerPrice != 0 {
+		edit.Price, edit.Price2 = result.TriggerPrice, result.LimitPrice
+	} else {
+		edit.Price = result.LimitPrice
+	}
+
+	return edit, nil
+}
+
+func (t *WSOrderTransport) AmendOrder(txid string, order *OrderRequest) (*AmendOrderResult, error) {
+	err := t.api.checkEndpoint(URL_PRIVATE_AMEND_ORDER)
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.api.checkOrderChange(txid, order.amendChange())
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]interface{}{"order_id": txid}
+
+	if order.Volume != 0 {
+		params["order_qty"] = order.Volume
+	}
+
+	limit_price, trigger_price := order.limitAndTriggerPrices()
+
+	if limit_price != 0 {
+		params["limit_price"] = limit_price
+	}
+
+	if trigger_price != 0 {
+		params["trigger_price"] = trigger_price
+	}
+
+	content, err := t.Client.call("amend_order", params, t.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AmendOrderResult{Txid: txid}
+
+	err = json.Unmarshal(content, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Cancel an order by transaction id or user reference id.
+func (t *WSOrderTransport) CancelOrder(txid string) (*CancelResult, error) {
+	err := t.api.checkEndpoint(URL_PRIVATE_CANCEL_ORDER)
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]interface{}{"order_id": []string{txid}}
+
+	if userref, err := strconv.Atoi(txid); err == nil {
+		params = map[string]interface{}{"order_userref": []int{userref}}
+	}
+
+	content, err := t.Client.call("cancel_order", params, t.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		OrderId string `json:"order_id"`
+		Count   *int   `json:"count"`
+	}
+
+	err = json.Unmarshal(content, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	// The response is for a single order, unless it gives a count
+	switch {
+	case result.Count != nil:
+		return &CancelResult{Count: *result.Count}, nil
+	case result.OrderId != "":
+		return &CancelResult{Count: 1}, nil
+	}
+
+	return &CancelResult{}, nil
+}
@@ -0,0 +1,198 @@
+package krakenapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Places orders through either transport.
+func placeTestOrder(transport OrderTransport, order *OrderRequest) (string, error) {
+	result, err := transport.AddOrder(order)
+	if err != nil {
+		return "", err
+	}
+
+	return result.Txid[0], nil
+}
+
+func TestWSOrderTransport(t *testing.T) {
+	api, rest := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case URL_PUBLIC_ASSETS:
+			fmt.Fprint(w, `{"error":[],"result":{"XXBT":{"altname":"XBT"},"ZEUR":{"altname":"EUR"},"DASH":{"altname":"DASH"}}}`)
+		case URL_PUBLIC_ASSET_PAIRS:
+			fmt.Fprint(w, `{"error":[],"result":{"XXBTZEUR":{"altname":"XBTEUR","wsname":"XBT/EUR","base":"XXBT","quote":"ZEUR"},"DASHEUR":{"altname":"DASHEUR","wsname":"DASH/EUR","base":"DASH","quote":"ZEUR"}}}`)
+		default:
+			fmt.Fprint(w, `{"error":[],"result":{"token":"secret","expires":900}}`)
+		}
+	})
+	defer rest.Close()
+
+	requests := make(chan wsCallV2, 10)
+
+	server, url := createTestWSServer(t, func(conn *websocket.Conn, message []byte) {
+		var request wsCallV2
+		json.Unmarshal(message, &request)
+		requests <- request
+
+		var result string
+
+		switch {
+		case request.Params["token"] != "secret":
+			result = `"success":false,"error":"EGeneral:Invalid token"`
+		case request.Method == "add_order" && request.Params["symbol"] == "DASH/EUR":
+			result = `"success":false,"error":"EOrder:Insufficient funds"`
+		case request.Method == "add_order":
+			result = `"success":true,"result":{"order_id":"OABC","cl_ord_id":"id"}`
+		case request.Method == "edit_order":
+			result = `"success":true,"result":{"order_id":"ODEF","original_order_id":"OABC"}`
+		case request.Method == "cancel_order" && request.Params["order_id"] == nil:
+			result = `"success":true,"result":{}`
+		case request.Method == "cancel_order" && fmt.Sprint(request.Params["order_id"]) == "[ODEF]":
+			result = `"success":true,"result":{"order_id":"ODEF"}`
+		case request.Method == "amend_order":
+			result = `"success":true,"result":{"amend_id":"AMEND","order_id":"OABC"}`
+		default:
+			return // no response, the request times out
+		}
+
+		conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"method":"%s","req_id":%d,%s}`, request.Method, request.ReqId, result)))
+	})
+	defer server.Close()
+
+	client := api.NewPrivateWSClient(10)
+	client.URL = url
+
+	err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	transport := api.NewWSOrderTransport(client)
+	transport.Timeout = 100 * time.Millisecond
+
+	order := &OrderRequest{
+		Pair:        "XXBTZEUR",
+		Type:        "sell",
+		OrderType:   "stop-loss-limit",
+		Price:       30000,
+		Price2:      29900,
+		Volume:      0.5,
+		Oflags:      "fciq",
+		TimeInForce: TIF_GTD,
+		StpType:     STP_CANCEL_OLDEST,
+		Trigger:     TRIGGER_INDEX,
+		Expiretm:    "1700000000",
+		Userref:     "42",
+	}
+
+	txid, err := placeTestOrder(transport, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if txid != "OABC" {
+		t.Errorf("Unexpected txid %s", txid)
+	}
+
+	request := <-requests
+	expected := `{"expire_time":"2023-11-14T22:13:20Z","fee_preference":"quote","limit_price":29900,"order_qty":0.5,"order_type":"stop-loss-limit","order_userref":42,"side":"sell","stp_type":"cancel_oldest","symbol":"BTC/EUR","time_in_force":"gtd","token":"secret","triggers":{"price":30000,"reference":"index"}}`
+	if params, _ := json.Marshal(request.Params); string(params) != expected {
+		t.Errorf("Unexpected add_order parameters %s", params)
+	}
+
+	edit, err := transport.EditOrder("OABC", &OrderRequest{Pair: "XBTEUR", OrderType: "limit", Price: 31000, PostOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if edit.Txid != "ODEF" || edit.OriginalTxid != "OABC" || edit.Price != 0 || edit.Volume != 0 {
+		t.Errorf("Unexpected edit result %+v", edit)
+	}
+
+	request = <-requests
+	expected = `{"limit_price":31000,"order_id":"OABC","post_only":true,"symbol":"BTC/EUR","token":"secret"}`
+	if params, _ := json.Marshal(request.Params); string(params) != expected {
+		t.Errorf("Unexpected edit_order parameters %s", params)
+	}
+
+	amend, err := transport.AmendOrder("OABC", &OrderRequest{Volume: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if amend.AmendId != "AMEND" || amend.Txid != "OABC" {
+		t.Errorf("Unexpected amend result %+v", amend)
+	}
+	<-requests
+
+	_, err = transport.AddOrder(&OrderRequest{Pair: "DASHEUR", Type: "buy", OrderType: "market", Volume: 1})
+	if _, ok := err.(*ApiError); !ok {
+		t.Errorf("Rejected order should return an *ApiError, got %v", err)
+	}
+	<-requests
+
+	_, err = transport.CancelOrder("OABC")
+	if _, ok := err.(*WSTimeoutError); !ok {
+		t.Errorf("Unanswered request should time out, got %v", err)
+	}
+
+	request = <-requests
+	if params, _ := json.Marshal(request.Params); string(params) != `{"order_id":["OABC"],"token":"secret"}` {
+		t.Errorf("Unexpected cancel_order parameters %s", params)
+	}
+
+	// Counts come from the responses
+	for txid, count := range map[string]int{"ODEF": 1, "42": 0} {
+		cancel, err := transport.CancelOrder(txid)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cancel.Count != count {
+			t.Errorf("Unexpected %s cancel count %d", txid, cancel.Count)
+		}
+		<-requests
+	}
+
+	// Orders are refused before being sent
+	_, err = transport.AddOrder(&OrderRequest{Pair: "UNKNOWN", Type: "buy", OrderType: "market", Volume: 1})
+	if _, ok := err.(*OrderValidationError); !ok {
+		t.Errorf("Order on an unknown pair should be refused, got %v", err)
+	}
+
+	_, err = transport.AddOrder(&OrderRequest{Pair: "XXBTZEUR", Type: "buy", OrderType: "market", Volume: 1, PostOnly: true})
+	if _, ok := err.(*OrderValidationError); !ok {
+		t.Errorf("Invalid order should be refused, got %v", err)
+	}
+
+	api.PreTradeCheck = func(order *OrderRequest) error { return &RiskError{Rule: RISK_ORDER_NOTIONAL} }
+
+	_, err = transport.AddOrder(&OrderRequest{Pair: "XXBTZEUR", Type: "buy", OrderType: "market", Volume: 1})
+	if _, ok := err.(*RiskError); !ok {
+		t.Errorf("Order should be refused by the pre-trade check, got %v", err)
+	}
+
+	api.PreTradeCheck = nil
+	api.Mode = MODE_TRADE_DISABLED
+
+	_, err = transport.AddOrder(&OrderRequest{Pair: "XXBTZEUR", Type: "buy", OrderType: "market", Volume: 1})
+	if _, ok := err.(*ModeError); !ok {
+		t.Errorf("Order should be refused by the client mode, got %v", err)
+	}
+
+	select {
+	case request := <-requests:
+		t.Errorf("Refused order sent: %+v", request)
+	default:
+	}
+
+	var _ OrderTransport = api.NewRestOrderTransport()
+}
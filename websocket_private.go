@@ -0,0 +1,232 @@
+package krakenapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	WS_URL_PRIVATE    = "wss://ws-auth.kraken.com"
+	WS_URL_PRIVATE_V2 = "wss://ws-auth.kraken.com/v2" // balances channel
+)
+
+// Tokens are renewed when they expire within this margin.
+var WSTokenRenewMargin = time.Minute
+
+var wsPrivateChannels = map[string]bool{
+	"ownTrades":  true,
+	"openOrders": true,
+	"balances":   true,
+}
+
+// Trades of the account, keyed by trade id. The first message after subscribing has the latest trades.
+type WSOwnTrades struct {
+	Trades   map[string]Trade
+	Sequence int
+}
+
+/*
+Open order, sent when it is added and each time it changes.
+
+Kraken only sends the changed fields: Order is the order with all updates so far
+applied. Once Status is closed, canceled or expired, the order is not tracked anymore.
+
+When updates were missed (after a reconnection, or a gap in sequences), open orders
+are reloaded with REST calls and sent with Resync set, including the tracked orders
+closed meanwhile.
+*/
+type WSOrder struct {
+	Txid     string
+	Order    Order
+	Sequence int
+	Resync   bool
+}
+
+// Balances of the account, by asset (ie: BTC, EUR). Snapshots have all the assets, updates the changed ones.
+type WSBalances struct {
+	Snapshot bool
+	Balances map[string]float64
+}
+
+type wsSequence struct {
+	Sequence int `json:"sequence"`
+}
+
+type wsParamsV2 struct {
+	Channel string `json:"channel"`
+	Token   string `json:"token"`
+}
+
+type wsRequestV2 struct {
+	Method string     `json:"method"`
+	Params wsParamsV2 `json:"params"`
+}
+
+/*
+WSTokenSource provides GetWebSocketsToken tokens to subscribe to private channels.
+
+A token is kept until it is about to expire (see WSTokenRenewMargin), then a new
+one is requested, so each subscription is made with a valid token.
+*/
+type WSTokenSource struct {
+	api *KrakenApi
+
+	mutex   sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (api *KrakenApi) NewWSTokenSource() *WSTokenSource {
+	return &WSTokenSource{api: api}
+}
+
+// Returns a token valid for at least WSTokenRenewMargin.
+func (s *WSTokenSource) Token() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+
+	if s.token != "" && now.Add(WSTokenRenewMargin).Before(s.expires) {
+		return s.token, nil
+	}
+
+	token, err := s.api.ApiGetWebSocketsToken()
+	if err != nil {
+		return "", fmt.Errorf("Could not get WebSockets token: %s", err.Error())
+	}
+
+	s.token = token.Token
+	s.expires = now.Add(time.Duration(token.Expires) * time.Second)
+
+	return s.token, nil
+}
+
+/*
+Create a client for the private WebSocket feed, with channels buffering up to buffer messages.
+
+Subscribe to ownTrades and openOrders (without pairs). The balances channel is only
+available on the v2 feed: subscribe to it from a client whose URL is WS_URL_PRIVATE_V2.
+*/
+func (api *KrakenApi) NewPrivateWSClient(buffer int) *WSClient {
+	client := NewWSClient(WS_URL_PRIVATE, buffer)
+	client.Tokens = api.NewWSTokenSource()
+	client.api = api
+
+	return client
+}
+
+func (c *WSClient) token() (string, error) {
+	if c.Tokens == nil {
+		return "", fmt.Errorf("Private WebSocket channels need a token source")
+	}
+
+	return c.Tokens.Token()
+}
+
+func (c *WSClient) handlePrivate(channel string, payload json.RawMessage, sequence int, stop chan struct{}) error {
+	switch channel {
+	case "ownTrades":
+		var entries []map[string]Trade
+
+		err := json.Unmarshal(payload, &entries)
+		if err != nil {
+			return err
+		}
+
+		err = c.sequence(channel, sequence)
+		if err != nil {
+			c.error(err)
+		}
+
+		trades := WSOwnTrades{Trades: make(map[string]Trade), Sequence: sequence}
+		for _, entry := range entries {
+			for txid, trade := range entry {
+				trades.Trades[txid] = trade
+			}
+		}
+
+		select {
+		case c.OwnTrades <- trades:
+		case <-stop:
+		}
+
+	case "openOrders":
+		var entries []map[string]json.RawMessage
+
+		err := json.Unmarshal(payload, &entries)
+		if err != nil {
+			return err
+		}
+
+		if c.sequence(channel, sequence) != nil || c.ordersGap {
+			c.ordersGap = false
+
+			err = c.resyncOrders(sequence, stop)
+			if err != nil {
+				c.ordersGap = true
+				return err
+			}
+		}
+
+		for _, entry := range entries {
+			for txid, update := range entry {
+				order := c.orders[txid]
+
+				err = json.Unmarshal(update, &order)
+				if err != nil {
+					return err
+				}
+
+				switch order.Status {
+				case "closed", "canceled", "expired":
+					delete(c.orders, txid)
+				default:
+					c.orders[txid] = order
+				}
+
+				select {
+				case c.OpenOrders <- WSOrder{txid, order, sequence, false}:
+				case <-stop:
+					return nil
+				}
+			}
+		}
+
+	default:
+		return fmt.Errorf("Unknown WebSocket channel %s", channel)
+	}
+
+	return nil
+}
+
+// {"channel": "balances", "type": "snapshot" or "update", "data": [{"asset": "BTC", "balance": 1.2, ...}]}
+func (c *WSClient) handleBalances(event wsEvent, stop chan struct{}) error {
+	if len(event.Data) == 0 {
+		return nil
+	}
+
+	var entries []struct {
+		Asset   string  `json:"asset"`
+		Balance float64 `json:"balance"`
+	}
+
+	err := json.Unmarshal(event.Data, &entries)
+	if err != nil {
+		return err
+	}
+
+	balances := WSBalances{Snapshot: event.Type == "snapshot", Balances: make(map[string]float64)}
+	for _, entry := range entries {
+		balances.Balances[entry.Asset] = entry.Balance
+	}
+
+	select {
+	case c.Balances <- balances:
+	case <-stop:
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package krakenapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var testWSPrivateMessages = map[string][]string{
+	"ownTrades": {`[[{"TDLH43-DVQXD-2KHVYY":{"cost":"1000000.00000","fee":"1600.00000","margin":"0.00000","ordertxid":"TDLH43-DVQXD-2KHVYY","ordertype":"limit","pair":"XBT/EUR","postxid":"OGTT3Y-C6I3P-XRI6HX","price":"100000.00000","time":"1560516023.070651","type":"sell","vol":"1000000000.00000000"}}],"ownTrades",{"sequence":2948}]`},
+	"openOrders": {
+		`[[{"OGTT3Y-C6I3P-XRI6HX":{"avg_price":"0.00000","cost":"0.00000","descr":{"ordertype":"limit","pair":"XBT/EUR","price":"34.50000","type":"sell"},"expiretm":"0.000000","fee":"0.00000","misc":"","oflags":"fcib","opentm":"1560516023.070651","refid":"OKIVMP-5GVZN-Z2D2UA","starttm":"0.000000","status":"open","userref":7,"vol":"10.00345345","vol_exec":"0.00000000"}}],"openOrders",{"sequence":234}]`,
+		`[[{"OGTT3Y-C6I3P-XRI6HX":{"vol_exec":"4.00000000","cost":"138.00000","fee":"0.22000","avg_price":"34.50000"}}],"openOrders",{"sequence":235}]`,
+		`[[{"OGTT3Y-C6I3P-XRI6HX":{"status":"canceled","cost":"138.00000","vol_exec":"4.00000000","fee":"0.22000","avg_price":"34.50000"}}],"openOrders",{"sequence":236}]`,
+	},
+	"balances": {
+		`{"channel":"balances","type":"snapshot","data":[{"asset":"BTC","asset_class":"currency","balance":1.2,"wallets":[]},{"asset":"EUR","asset_class":"currency","balance":1000,"wallets":[]}],"sequence":1}`,
+		`{"channel":"balances","type":"update","data":[{"asset":"BTC","amount":-0.2,"balance":1.0,"type":"trade","ledger_id":"L4UESK-KG3EQ-UFO4T5"}],"sequence":2}`,
+	},
+}
+
+func TestWebSocketsTokenSource(t *testing.T) {
+	var requests int32
+
+	expires := 900
+	api, server := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != URL_PRIVATE_WEBSOCKETS_TOKEN {
+			t.Errorf("Unexpected request %s", r.URL.Path)
+		}
+
+		n := atomic.AddInt32(&requests, 1)
+		fmt.Fprintf(w, `{"error":[],"result":{"token":"token-%d","expires":%d}}`, n, expires)
+	})
+	defer server.Close()
+
+	tokens := api.NewWSTokenSource()
+
+	for i := 0; i < 2; i++ {
+		token, err := tokens.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if token != "token-1" {
+			t.Errorf("Unexpected token %s", token)
+		}
+	}
+
+	// Tokens expiring within the renew margin are renewed
+	tokens.expires = time.Now().Add(WSTokenRenewMargin / 2)
+
+	token, err := tokens.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token != "token-2" {
+		t.Errorf("Token not renewed: %s", token)
+	}
+
+	api.Mode = MODE_READ_ONLY
+	tokens.expires = time.Time{}
+
+	_, err = tokens.Token()
+	if err == nil {
+		t.Error("Read only clients should not get WebSockets tokens")
+	}
+
+	api.Mode = MODE_TRADE_DISABLED
+
+	_, err = tokens.Token()
+	if err != nil {
+		t.Errorf("Trade disabled clients should get WebSockets tokens: %s", err)
+	}
+}
+
+func TestWSClientPrivate(t *testing.T) {
+	api, rest := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"error":[],"result":{"token":"secret","expires":900}}`)
+	})
+	defer rest.Close()
+
+	server, url := createTestWSServer(t, func(conn *websocket.Conn, message []byte) {
+		var request struct {
+			wsRequest
+			wsRequestV2
+		}
+		json.Unmarshal(message, &request)
+
+		name, token := "balances", request.Params.Token
+		if request.Subscription != nil {
+			name, token = request.Subscription.Name, request.Subscription.Token
+		}
+
+		if token != "secret" {
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"event":"subscriptionStatus","status":"error","errorMessage":"EGeneral:Invalid token"}`))
+			return
+		}
+
+		for _, data := range testWSPrivateMessages[name] {
+			conn.WriteMessage(websocket.TextMessage, []byte(data))
+		}
+	})
+	defer server.Close()
+
+	errors := make(chan error, 10)
+
+	client := api.NewPrivateWSClient(10)
+	client.URL = url
+	client.OnError = func(err error) { errors <- err }
+
+	err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	for _, name := range []string{"ownTrades", "openOrders", "balances"} {
+		err = client.Subscribe(WSSubscription{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	timeout := time.After(time.Second)
+
+	select {
+	case trades := <-client.OwnTrades:
+		trade := trades.Trades["TDLH43-DVQXD-2KHVYY"]
+		if trades.Sequence != 2948 || trade.Time != 1560516023.070651 || trade.Price != 100000 || trade.Type != "sell" {
+			t.Errorf("Unexpected trades %+v", trades)
+		}
+	case err := <-errors:
+		t.Fatal(err)
+	case <-timeout:
+		t.Fatal("No trades")
+	}
+
+	var order WSOrder
+
+	for i := 0; i < 3; i++ {
+		select {
+		case order = <-client.OpenOrders:
+		case err := <-errors:
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatal("No order")
+		}
+
+		if i == 1 && (order.Order.Status != "open" || order.Order.VolExec != 4 || order.Order.Vol != 10.00345345 || order.Order.Price != 34.5) {
+			t.Errorf("Update not merged %+v", order)
+		}
+	}
+
+	if order.Txid != "OGTT3Y-C6I3P-XRI6HX" || order.Sequence != 236 || order.Order.Status != "canceled" || order.Order.Userref != "7" || order.Order.Opentm != 1560516023.070651 || order.Order.Descr.Price != 34.5 {
+		t.Errorf("Unexpected order %+v", order)
+	}
+
+	for _, expected := range []WSBalances{{true, map[string]float64{"BTC": 1.2, "EUR": 1000}}, {false, map[string]float64{"BTC": 1}}} {
+		select {
+		case balances := <-client.Balances:
+			if balances.Snapshot != expected.Snapshot || fmt.Sprint(balances.Balances) != fmt.Sprint(expected.Balances) {
+				t.Errorf("Unexpected balances %+v", balances)
+			}
+		case err := <-errors:
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatal("No balances")
+		}
+	}
+
+	public := NewWSClient(url, 1)
+	if err := public.Subscribe(WSSubscription{Name: "ownTrades"}); err == nil {
+		t.Error("Private subscription without token source should fail")
+	}
+}
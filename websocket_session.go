@@ -0,0 +1,317 @@
+package krakenapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// System status values (WSStatus.Status).
+const (
+	WS_STATUS_ONLINE      = "online"
+	WS_STATUS_MAINTENANCE = "maintenance" // no trading, open orders may be canceled
+	WS_STATUS_CANCEL_ONLY = "cancel_only" // orders can only be canceled
+	WS_STATUS_LIMIT_ONLY  = "limit_only"  // only limit orders can be added
+	WS_STATUS_POST_ONLY   = "post_only"   // only post only limit orders can be added
+)
+
+// Kraken's system status, sent by the feed on connection and when it changes.
+type WSStatus struct {
+	Status  string // see WS_STATUS_*
+	Version string // feed version
+}
+
+// Returned (to OnError) when messages of a sequenced channel were missed.
+type WSGapError struct {
+	Channel  string
+	Expected int // sequence of the next expected message
+	Received int
+}
+
+func (e *WSGapError) Error() string {
+	return fmt.Sprintf("WebSocket %s messages missed: expected sequence %d, received %d", e.Channel, e.Expected, e.Received)
+}
+
+// Pairs subscribed to a channel.
+type wsSubscriptions struct {
+	subscription WSSubscription
+	pairs        map[string]bool
+}
+
+func subscriptionKey(subscription WSSubscription) string {
+	return fmt.Sprintf("%s/%d/%d", subscription.Name, subscription.Interval, subscription.Depth)
+}
+
+func (c *WSClient) remember(subscription WSSubscription, pairs []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.subscriptions == nil {
+		c.subscriptions = make(map[string]*wsSubscriptions)
+	}
+
+	subscription.Token = ""
+	key := subscriptionKey(subscription)
+
+	subscribed, ok := c.subscriptions[key]
+	if !ok {
+		subscribed = &wsSubscriptions{subscription, make(map[string]bool)}
+		c.subscriptions[key] = subscribed
+	}
+
+	for _, pair := range pairs {
+		subscribed.pairs[pair] = true
+	}
+}
+
+func (c *WSClient) forgetSubscription(subscription WSSubscription, pairs []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := subscriptionKey(subscription)
+
+	subscribed, ok := c.subscriptions[key]
+	if !ok {
+		return
+	}
+
+	for _, pair := range pairs {
+		delete(subscribed.pairs, pair)
+	}
+
+	if len(pairs) == 0 || len(subscribed.pairs) == 0 {
+		delete(c.subscriptions, key)
+	}
+}
+
+// Subscribe again to all the subscribed channels.
+func (c *WSClient) replay() error {
+	c.mutex.Lock()
+	subscriptions := make([]wsSubscriptions, 0, len(c.subscriptions))
+	for _, subscribed := range c.subscriptions {
+		subscriptions = append(subscriptions, *subscribed)
+	}
+	c.mutex.Unlock()
+
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptionKey(subscriptions[i].subscription) < subscriptionKey(subscriptions[j].subscription)
+	})
+
+	for _, subscribed := range subscriptions {
+		pairs := []string{}
+		for pair := range subscribed.pairs {
+			pairs = append(pairs, pair)
+		}
+		sort.Strings(pairs)
+
+		err := c.request("subscribe", subscribed.subscription, pairs)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Minimum delay between reconnection attempts, whatever ReconnectDelay and MaxReconnectDelay are.
+var WSMinReconnectDelay = 100 * time.Millisecond
+
+// Reconnect with an exponential backoff. Returns nil if the client was closed meanwhile.
+func (c *WSClient) reconnect(stop chan struct{}) *websocket.Conn {
+	delay := c.ReconnectDelay
+
+	for {
+		if delay < WSMinReconnectDelay {
+			delay = WSMinReconnectDelay
+		}
+
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if c.MaxReconnectDelay > 0 && delay > c.MaxReconnectDelay {
+			delay = c.MaxReconnectDelay
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		conn, _, err := c.Dialer.DialContext(ctx, c.URL, nil)
+		cancel()
+
+		if err != nil {
+			c.error(fmt.Errorf("Could not reconnect WebSocket: %s", err.Error()))
+			continue
+		}
+
+		c.mutex.Lock()
+		select {
+		case <-stop:
+			c.mutex.Unlock()
+			conn.Close()
+			return nil
+		default:
+		}
+
+		c.conn = conn
+		c.mutex.Unlock()
+
+		// Sequences restart on the new connection, and open orders may have changed meanwhile
+		c.sequences = make(map[string]int)
+		c.ordersGap = true
+
+		err = c.replay()
+		if err != nil {
+			c.error(fmt.Errorf("Could not subscribe again after reconnecting: %s", err.Error()))
+			conn.Close()
+			continue
+		}
+
+		return conn
+	}
+}
+
+// Ping conn every PingInterval, until reading stops.
+func (c *WSClient) ping(conn *websocket.Conn, reading chan struct{}) {
+	if c.PingInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-reading:
+			return
+		case <-ticker.C:
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.PingInterval))
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Fail the current read if nothing is received for StallTimeout.
+func (c *WSClient) extendDeadline(conn *websocket.Conn) {
+	if c.StallTimeout <= 0 {
+		conn.SetReadDeadline(time.Time{})
+		return
+	}
+
+	conn.SetReadDeadline(time.Now().Add(c.StallTimeout))
+}
+
+// {"channel": "status", "type": "update", "data": [{"system": "online", "version": "2.0.0", ...}]}
+func (c *WSClient) handleStatusV2(event wsEvent, stop chan struct{}) error {
+	var entries []struct {
+		System  string `json:"system"`
+		Version string `json:"version"`
+	}
+
+	err := json.Unmarshal(event.Data, &entries)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		select {
+		case c.Status <- WSStatus{entry.System, entry.Version}:
+		case <-stop:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// Record the sequence of a private channel message. Returns a *WSGapError if messages were missed.
+func (c *WSClient) sequence(channel string, sequence int) error {
+	last, ok := c.sequences[channel]
+	c.sequences[channel] = sequence
+
+	if ok && sequence > last+1 {
+		return &WSGapError{channel, last + 1, sequence}
+	}
+
+	return nil
+}
+
+/*
+Reload open orders from OpenOrders, after open orders updates were missed. Orders
+which are not open anymore are loaded from QueryOrders. All of them are sent to
+OpenOrders, with Resync set.
+*/
+func (c *WSClient) resyncOrders(sequence int, stop chan struct{}) error {
+	if c.api == nil {
+		return fmt.Errorf("Could not resync open orders: no REST client")
+	}
+
+	open, err := c.api.ApiOpenOrders(false, "")
+	if err != nil {
+		return fmt.Errorf("Could not resync open orders: %s", err.Error())
+	}
+
+	orders := make(map[string]Order)
+	for txid, order := range open.Open {
+		orders[txid] = order
+	}
+
+	missing := []string{}
+	for txid := range c.orders {
+		if _, ok := orders[txid]; !ok {
+			missing = append(missing, txid)
+		}
+	}
+	sort.Strings(missing)
+
+	if len(missing) > 0 {
+		closed, err := c.api.ApiQueryOrders(false, "", strings.Join(missing, ","))
+		if err != nil {
+			return fmt.Errorf("Could not resync open orders: %s", err.Error())
+		}
+
+		for txid, order := range *closed {
+			orders[txid] = order
+		}
+	}
+
+	c.orders = make(map[string]Order)
+
+	txids := make([]string, 0, len(orders))
+	for txid := range orders {
+		txids = append(txids, txid)
+	}
+	sort.Strings(txids)
+
+	for _, txid := range txids {
+		order := orders[txid]
+
+		if order.Status == "open" || order.Status == "pending" {
+			c.orders[txid] = order
+		}
+
+		select {
+		case c.OpenOrders <- WSOrder{txid, order, sequence, true}:
+		case <-stop:
+			return nil
+		}
+	}
+
+	return nil
+}
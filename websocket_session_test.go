@@ -0,0 +1,194 @@
+package krakenapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWSClientReconnect(t *testing.T) {
+	var mutex sync.Mutex
+	var stalled *websocket.Conn
+	release := make(chan struct{})
+	requests := make(chan wsRequest, 10)
+
+	server, url := createTestWSServer(t, func(conn *websocket.Conn, message []byte) {
+		mutex.Lock()
+		if stalled == nil {
+			stalled = conn
+		}
+		stall := conn == stalled
+		mutex.Unlock()
+
+		// The first connection stops responding, even to pings
+		if stall {
+			<-release
+			return
+		}
+
+		var request wsRequest
+		json.Unmarshal(message, &request)
+		requests <- request
+
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"connectionID":1,"event":"systemStatus","status":"maintenance","version":"1.9.0"}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(testWSMessages["ticker"][0]))
+	})
+	defer server.Close()
+	defer close(release)
+
+	errors := make(chan error, 10)
+
+	client := NewWSClient(url, 10)
+	client.OnError = func(err error) { errors <- err }
+	client.ReconnectDelay = 10 * time.Millisecond
+	client.PingInterval = 20 * time.Millisecond
+	client.StallTimeout = 100 * time.Millisecond
+
+	err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	err = client.Subscribe(WSSubscription{Name: "ticker"}, "XBT/EUR", "ETH/EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Unsubscribe(WSSubscription{Name: "ticker"}, "ETH/EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(2 * time.Second)
+
+	select {
+	case err := <-errors:
+		if err == nil {
+			t.Error("Stall should be reported")
+		}
+	case <-timeout:
+		t.Fatal("Stall not detected")
+	}
+
+	select {
+	case request := <-requests:
+		if request.Event != "subscribe" || request.Subscription.Name != "ticker" || len(request.Pair) != 1 || request.Pair[0] != "XBT/EUR" {
+			t.Errorf("Unexpected replayed subscription %+v", request)
+		}
+	case <-timeout:
+		t.Fatal("Subscription not replayed")
+	}
+
+	select {
+	case status := <-client.Status:
+		if status.Status != WS_STATUS_MAINTENANCE || status.Version != "1.9.0" {
+			t.Errorf("Unexpected status %+v", status)
+		}
+	case <-timeout:
+		t.Fatal("No status")
+	}
+
+	select {
+	case ticker := <-client.Tickers:
+		if ticker.Pair != "XBT/EUR" {
+			t.Errorf("Unexpected ticker %+v", ticker)
+		}
+	case <-timeout:
+		t.Fatal("No ticker after reconnecting")
+	}
+}
+
+func TestWSClientSequenceGap(t *testing.T) {
+	api, rest := createTestApiClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case URL_PRIVATE_WEBSOCKETS_TOKEN:
+			fmt.Fprint(w, `{"error":[],"result":{"token":"secret","expires":900}}`)
+		case URL_PRIVATE_OPEN_ORDERS:
+			fmt.Fprint(w, `{"error":[],"result":{"open":{"OAAA":{"status":"open","vol":"2.00000000","vol_exec":"1.00000000"}}}}`)
+		case URL_PRIVATE_QUERY_ORDERS:
+			if r.FormValue("txid") != "OBBB" {
+				t.Errorf("Unexpected query %s", r.FormValue("txid"))
+			}
+			fmt.Fprint(w, `{"error":[],"result":{"OBBB":{"status":"canceled","vol":"3.00000000","vol_exec":"0.00000000"}}}`)
+		default:
+			t.Errorf("Unexpected request %s", r.URL.Path)
+		}
+	})
+	defer rest.Close()
+
+	messages := map[string][]string{
+		"openOrders": {
+			`[[{"OAAA":{"status":"open","vol":"2.00000000","vol_exec":"0.00000000"}},{"OBBB":{"status":"open","vol":"3.00000000","vol_exec":"0.00000000"}}],"openOrders",{"sequence":1}]`,
+			`[[{"OAAA":{"vol_exec":"1.50000000"}}],"openOrders",{"sequence":3}]`,
+		},
+		"ownTrades": {
+			`[[{"TAAA":{"ordertxid":"OAAA","time":"1560516023.070651","type":"buy","vol":"1.00000000"}}],"ownTrades",{"sequence":1}]`,
+			`[[{"TBBB":{"ordertxid":"OAAA","time":"1560516024.070651","type":"buy","vol":"0.50000000"}}],"ownTrades",{"sequence":4}]`,
+		},
+	}
+
+	server, url := createTestWSServer(t, func(conn *websocket.Conn, message []byte) {
+		var request wsRequest
+		json.Unmarshal(message, &request)
+
+		for _, data := range messages[request.Subscription.Name] {
+			conn.WriteMessage(websocket.TextMessage, []byte(data))
+		}
+	})
+	defer server.Close()
+
+	errors := make(chan error, 10)
+
+	client := api.NewPrivateWSClient(10)
+	client.URL = url
+	client.OnError = func(err error) { errors <- err }
+
+	err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	err = client.Subscribe(WSSubscription{Name: "openOrders"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(time.Second)
+	expected := []string{"OAAA open false 0", "OBBB open false 0", "OAAA open true 1", "OBBB canceled true 0", "OAAA open false 1.5"}
+
+	for _, expect := range expected {
+		select {
+		case order := <-client.OpenOrders:
+			if got := fmt.Sprint(order.Txid, " ", order.Order.Status, " ", order.Resync, " ", order.Order.VolExec); got != expect {
+				t.Errorf("Unexpected order %s, expected %s", got, expect)
+			}
+		case err := <-errors:
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatal("Missing orders")
+		}
+	}
+
+	err = client.Subscribe(WSSubscription{Name: "ownTrades"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errors:
+		gap, ok := err.(*WSGapError)
+		if !ok || gap.Channel != "ownTrades" || gap.Expected != 2 || gap.Received != 4 {
+			t.Errorf("Unexpected error %v", err)
+		}
+	case <-timeout:
+		t.Fatal("Gap not reported")
+	}
+}
@@ -0,0 +1,157 @@
+package krakenapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Returns a local WebSocket server calling handler for each message it receives, and its URL.
+func createTestWSServer(t *testing.T, handler func(conn *websocket.Conn, message []byte)) (*httptest.Server, string) {
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			handler(conn, message)
+		}
+	}))
+
+	return server, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+var testWSMessages = map[string][]string{
+	"ticker": {`[340,{"a":["5525.40000",1,"1.000"],"b":["5525.10000",1,"1.000"],"c":["5525.10000","0.00398963"],"v":["2634.11501494","3591.17907851"],"p":["5631.44067","5653.78939"],"t":[11493,16267],"l":["5505.00000","5505.00000"],"h":["5783.00000","5783.00000"],"o":["5760.70000","5763.40000"]},"ticker","XBT/EUR"]`},
+	"ohlc":   {`[42,["1542057314.748456","1542057360.435743","3586.70000","3586.70000","3586.60000","3586.60000","3586.68894","0.03373000",2],"ohlc-5","XBT/EUR"]`},
+	"trade":  {`[0,[["5541.20000","0.15850568","1534614057.321597","s","l",""],["6060.00000","0.02455000","1534614057.324998","b","l",""]],"trade","XBT/EUR"]`},
+	"spread": {`[0,["5698.40000","5700.00000","1542057299.545897","1.01234567","0.98765432"],"spread","XBT/EUR"]`},
+	"book": {
+		`[0,{"as":[["5541.30000","2.50700000","1534614248.123678"],["5541.80000","0.33000000","1534614098.345543"]],"bs":[["5541.20000","1.52900000","1534614248.765567"]]},"book-10","XBT/EUR"]`,
+		`[1234,{"a":[["5541.30000","0.00000000","1534614335.345903"]]},{"b":[["5541.20000","2.00000000","1534614335.345903","r"]],"c":"974942666"},"book-10","XBT/EUR"]`,
+	},
+}
+
+func TestWSClientPublic(t *testing.T) {
+	server, url := createTestWSServer(t, func(conn *websocket.Conn, message []byte) {
+		var request wsRequest
+		json.Unmarshal(message, &request)
+
+		if request.Subscription.Name == "unknown" {
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"event":"subscriptionStatus","status":"error","pair":"XBT/EUR","errorMessage":"Subscription name invalid"}`))
+			return
+		}
+
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"event":"subscriptionStatus","status":"subscribed","pair":"XBT/EUR","channelName":"`+request.Subscription.Name+`"}`))
+		for _, data := range testWSMessages[request.Subscription.Name] {
+			conn.WriteMessage(websocket.TextMessage, []byte(data))
+		}
+	})
+	defer server.Close()
+
+	errors := make(chan error, 10)
+
+	client := NewWSClient(url, 10)
+	client.OnError = func(err error) { errors <- err }
+
+	err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	for _, subscription := range []WSSubscription{{Name: "ticker"}, {Name: "ohlc", Interval: 5}, {Name: "trade"}, {Name: "spread"}, {Name: "book", Depth: 10}, {Name: "unknown"}} {
+		err = client.Subscribe(subscription, "XBT/EUR")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	timeout := time.After(time.Second)
+
+	select {
+	case ticker := <-client.Tickers:
+		if ticker.Pair != "XBT/EUR" || ticker.Ticker.Ask.Price != 5525.4 || ticker.Ticker.OpeningPrice != 5760.7 || ticker.Ticker.Trades[1] != 16267 {
+			t.Errorf("Unexpected ticker %+v", ticker)
+		}
+	case <-timeout:
+		t.Fatal("No ticker")
+	}
+
+	select {
+	case candle := <-client.Candles:
+		if candle.Interval != OHLC_5M || candle.EndTime != 1542057360.435743 || candle.Candle.VWAP != 3586.68894 || candle.Candle.Count != 2 {
+			t.Errorf("Unexpected candle %+v", candle)
+		}
+	case <-timeout:
+		t.Fatal("No candle")
+	}
+
+	select {
+	case trades := <-client.Trades:
+		if len(trades.Trades) != 2 || trades.Trades[0].Price != 5541.2 || trades.Trades[1].Type != "b" {
+			t.Errorf("Unexpected trades %+v", trades)
+		}
+	case <-timeout:
+		t.Fatal("No trades")
+	}
+
+	select {
+	case spread := <-client.Spreads:
+		if spread.Spread.Bid != 5698.4 || spread.Spread.Ask != 5700 || spread.AskVolume != 0.98765432 {
+			t.Errorf("Unexpected spread %+v", spread)
+		}
+	case <-timeout:
+		t.Fatal("No spread")
+	}
+
+	book := NewOrderBook("XBT/EUR", 10)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case update := <-client.Books:
+			if update.Snapshot {
+				book.Load(PublicOrderBook{Asks: update.Asks, Bids: update.Bids})
+			} else {
+				book.Update(update.Asks, update.Bids)
+
+				if update.Checksum != "974942666" {
+					t.Errorf("Unexpected checksum %s", update.Checksum)
+				}
+			}
+		case <-timeout:
+			t.Fatal("No book")
+		}
+	}
+
+	ask, _ := book.BestAsk()
+	bid, _ := book.BestBid()
+	if ask.Price != 5541.8 || bid.Volume != 2 {
+		t.Errorf("Unexpected book %v", book.Snapshot())
+	}
+
+	select {
+	case err := <-errors:
+		if _, ok := err.(*WSError); !ok {
+			t.Errorf("Unexpected error %v", err)
+		}
+	case <-timeout:
+		t.Fatal("Refused subscription not reported")
+	}
+}